@@ -12,6 +12,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -99,9 +100,140 @@ type Client struct {
 	ApiKey   string
 	ApiToken string
 	Debug    bool
+	DryRun   bool
+
+	// OnMutation, if set, is called after every request that changes
+	// servers, e.g. to keep an audit log.
+	OnMutation func(Mutation)
+}
+
+// Mutation describes a request to an endpoint that changes servers and its
+// outcome. Credentials are removed from Params and secrets are redacted.
+type Mutation struct {
+	Path     string
+	Params   map[string]string
+	ServerId string
+	Success  bool
+	Error    string
+	Latency  time.Duration
+}
+
+// mutatingPaths lists the endpoints that change servers. In dry run mode
+// requests to them are printed instead of sent.
+var mutatingPaths = map[string]bool{
+	"start/single":         true,
+	"stop/single":          true,
+	"restart/single":       true,
+	"delete/single":        true,
+	"deploy/single/custom": true,
+	"modify/single/custom": true,
+}
+
+// secretParams are redacted when printing dry run requests.
+var secretParams = []string{"api_key", "api_token", "admin_pass"}
+
+func redactValues(values url.Values) url.Values {
+	redacted := url.Values{}
+	for key, elem := range values {
+		redacted[key] = elem
+	}
+	for _, key := range secretParams {
+		if redacted.Has(key) {
+			redacted.Set(key, "REDACTED")
+		}
+	}
+	return redacted
 }
 
+// dryRun prints the request with secrets redacted and returns a successful
+// response without sending it.
+func (client *Client) dryRun(req *http.Request, body []byte) (*json.RawMessage, error) {
+	shown := req.Clone(req.Context())
+	shown.URL.RawQuery = redactValues(req.URL.Query()).Encode()
+
+	if len(body) > 0 {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		redacted := []byte(redactValues(values).Encode())
+		shown.Body = ioutil.NopCloser(bytes.NewReader(redacted))
+		shown.ContentLength = int64(len(redacted))
+	}
+
+	dump, err := httputil.DumpRequestOut(shown, true)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("dry run, not sending:\n%v\n\n", strings.TrimSpace(string(dump)))
+
+	msg := json.RawMessage(`{"success":true}`)
+	return &msg, nil
+}
+
+// do sends a request, reporting it to OnMutation if it changes servers.
+// Dry runs are not reported since nothing was changed.
 func (client *Client) do(method string, path string, params map[string]string, headers map[string]string, body []byte) (*json.RawMessage, error) {
+	if client.OnMutation == nil || client.DryRun || !mutatingPaths[path] {
+		return client.send(method, path, params, headers, body)
+	}
+
+	start := time.Now()
+	raw, err := client.send(method, path, params, headers, body)
+	client.OnMutation(newMutation(path, params, body, raw, err, time.Since(start)))
+
+	return raw, err
+}
+
+func newMutation(path string, params map[string]string, body []byte, raw *json.RawMessage, err error, latency time.Duration) Mutation {
+	values := url.Values{}
+	for key, elem := range params {
+		values.Set(key, elem)
+	}
+	if parsed, err := url.ParseQuery(string(body)); err == nil {
+		for key, elem := range parsed {
+			values[key] = elem
+		}
+	}
+	values.Del("api_key")
+	values.Del("api_token")
+
+	mutation := Mutation{Path: path, Params: map[string]string{}, Latency: latency}
+	redacted := redactValues(values)
+	for key := range redacted {
+		mutation.Params[key] = redacted.Get(key)
+	}
+
+	mutation.ServerId = mutation.Params["server"]
+	if mutation.ServerId == "" {
+		mutation.ServerId = mutation.Params["server_id"]
+	}
+
+	if err != nil {
+		mutation.Error = err.Error()
+		return mutation
+	}
+
+	var res Response
+	if err := json.Unmarshal(*raw, &res); err != nil {
+		mutation.Error = err.Error()
+		return mutation
+	}
+
+	mutation.Success = res.Success
+	mutation.Error = res.Error
+
+	// deploy responses carry the ID of the new server
+	var deployed DeployServerResponse
+	if mutation.ServerId == "" && json.Unmarshal(*raw, &deployed) == nil {
+		mutation.ServerId = deployed.Server.Id
+	}
+
+	return mutation
+}
+
+func (client *Client) send(method string, path string, params map[string]string, headers map[string]string, body []byte) (*json.RawMessage, error) {
 	query := url.Values{}
 	for key, elem := range params {
 		query.Add(key, elem)
@@ -117,6 +249,10 @@ func (client *Client) do(method string, path string, params map[string]string, h
 		req.Header.Add(key, elem)
 	}
 
+	if client.DryRun && mutatingPaths[path] {
+		return client.dryRun(req, body)
+	}
+
 	if client.Debug {
 		reqDump, err := httputil.DumpRequestOut(req, true)
 		if err != nil {
@@ -341,7 +477,7 @@ func (client *Client) GetBillingDetails() (*GetBillingDetailsResponse, error) {
 }
 
 func NewClient(baseUrl string, apiKey string, apiToken string, debug bool) *Client {
-	return &Client{baseUrl, apiKey, apiToken, debug}
+	return &Client{BaseUrl: baseUrl, ApiKey: apiKey, ApiToken: apiToken, Debug: debug}
 }
 
 func (client *Client) RestartServer(server string) (*Response, error) {
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testKey   = "key-0123456789"
+	testToken = "token-0123456789"
+	testPass  = "pass-0123456789"
+)
+
+func assertNoSecrets(t *testing.T, name string, mutation Mutation) {
+	t.Helper()
+
+	for _, key := range []string{"api_key", "api_token"} {
+		if _, ok := mutation.Params[key]; ok {
+			t.Errorf("%v: %v was recorded", name, key)
+		}
+	}
+
+	if pass, ok := mutation.Params["admin_pass"]; ok && pass != "REDACTED" {
+		t.Errorf("%v: admin_pass recorded as %q", name, pass)
+	}
+
+	for key, value := range mutation.Params {
+		for _, secret := range []string{testKey, testToken, testPass} {
+			if strings.Contains(value, secret) {
+				t.Errorf("%v: secret recorded in %v", name, key)
+			}
+		}
+	}
+}
+
+func TestNewMutation(t *testing.T) {
+	success := json.RawMessage(`{"success":true}`)
+	failure := json.RawMessage(`{"success":false,"error":"Insufficient balance"}`)
+	deployed := json.RawMessage(`{"success":true,"server":{"id":"new1"}}`)
+
+	tests := []struct {
+		name     string
+		path     string
+		params   map[string]string
+		body     string
+		raw      *json.RawMessage
+		err      error
+		serverId string
+		success  bool
+		error    string
+	}{
+		{
+			name:     "credentials in query",
+			path:     "stop/single",
+			params:   map[string]string{"api_key": testKey, "api_token": testToken, "server": "s1"},
+			raw:      &success,
+			serverId: "s1",
+			success:  true,
+		},
+		{
+			name:     "credentials in body",
+			path:     "delete/single",
+			body:     "api_key=" + testKey + "&api_token=" + testToken + "&server=s2",
+			raw:      &success,
+			serverId: "s2",
+			success:  true,
+		},
+		{
+			name:     "deploy with admin password",
+			path:     "deploy/single/custom",
+			body:     "api_key=" + testKey + "&api_token=" + testToken + "&admin_pass=" + testPass + "&name=train-01",
+			raw:      &deployed,
+			serverId: "new1",
+			success:  true,
+		},
+		{
+			name:     "modify uses server_id",
+			path:     "modify/single/custom",
+			params:   map[string]string{"api_key": testKey, "api_token": testToken},
+			body:     "server_id=s3&ram=32",
+			raw:      &success,
+			serverId: "s3",
+			success:  true,
+		},
+		{
+			name:  "api error",
+			path:  "deploy/single/custom",
+			body:  "api_key=" + testKey + "&admin_pass=" + testPass,
+			raw:   &failure,
+			error: "Insufficient balance",
+		},
+		{
+			name:     "transport error",
+			path:     "start/single",
+			params:   map[string]string{"api_key": testKey, "api_token": testToken, "server": "s1"},
+			err:      errors.New("connection refused"),
+			serverId: "s1",
+			error:    "connection refused",
+		},
+	}
+
+	for _, test := range tests {
+		mutation := newMutation(test.path, test.params, []byte(test.body), test.raw, test.err, time.Second)
+
+		assertNoSecrets(t, test.name, mutation)
+
+		if mutation.ServerId != test.serverId {
+			t.Errorf("%v: got server %q, want %q", test.name, mutation.ServerId, test.serverId)
+		}
+
+		if mutation.Success != test.success || mutation.Error != test.error {
+			t.Errorf("%v: got success %v error %q, want %v %q", test.name, mutation.Success, mutation.Error, test.success, test.error)
+		}
+	}
+}
+
+func TestDeployMutationIsRedacted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"server":{"id":"new1"}}`))
+	}))
+	defer server.Close()
+
+	mutations := []Mutation{}
+	client := NewClient(server.URL, testKey, testToken, false)
+	client.OnMutation = func(mutation Mutation) {
+		mutations = append(mutations, mutation)
+	}
+
+	req := validDeployRequest()
+	req.AdminPass = testPass
+	if _, err := client.DeployServer(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mutations) != 1 {
+		t.Fatalf("got %v mutations, want 1", len(mutations))
+	}
+
+	assertNoSecrets(t, "deploy", mutations[0])
+
+	if mutations[0].Params["admin_pass"] != "REDACTED" {
+		t.Errorf("admin_pass recorded as %q, want REDACTED", mutations[0].Params["admin_pass"])
+	}
+}
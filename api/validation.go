@@ -0,0 +1,184 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StorageClasses are the storage classes the deploy endpoint accepts.
+var StorageClasses = []string{"io1", "st1"}
+
+// These bounds are not published TensorDock limits. They describe the
+// configurations usually offered (at most 8 GPUs per server, 1-8GB of RAM
+// per vCPU and the 20GB default disk), so requests outside them only get a
+// warning and the API decides.
+const (
+	MaxGPUCount   = 8
+	MinRAMPerVCPU = 1
+	MaxRAMPerVCPU = 8
+	MinStorage    = 20
+)
+
+// ValidationError collects every problem found in a request so they can be
+// reported together instead of one API round trip at a time.
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return "invalid request:\n  - " + strings.Join(err.Problems, "\n  - ")
+}
+
+func (err *ValidationError) Add(format string, args ...interface{}) {
+	err.Problems = append(err.Problems, fmt.Sprintf(format, args...))
+}
+
+// OrNil returns nil when no problems were found so the result can be
+// returned directly as an error.
+func (err *ValidationError) OrNil() error {
+	if len(err.Problems) == 0 {
+		return nil
+	}
+	return err
+}
+
+func validateResources(err *ValidationError, vcpus *int, ram *int, storage *int) {
+	if vcpus != nil && *vcpus <= 0 {
+		err.Add("vcpus must be positive, got %v", *vcpus)
+	}
+
+	if ram != nil && *ram <= 0 {
+		err.Add("ram must be positive, got %v", *ram)
+	}
+
+	if storage != nil && *storage <= 0 {
+		err.Add("storage must be positive, got %v", *storage)
+	}
+}
+
+// resourceWarnings flags sizes outside the usual bounds. The API may still
+// accept them, so these are only hints.
+func resourceWarnings(gpuCount *int, vcpus *int, ram *int, storage *int) []string {
+	warnings := []string{}
+
+	if gpuCount != nil && *gpuCount > MaxGPUCount {
+		warnings = append(warnings, fmt.Sprintf("%v GPUs is more than the usual maximum of %v per server", *gpuCount, MaxGPUCount))
+	}
+
+	if vcpus != nil && ram != nil && *vcpus > 0 && *ram > 0 && (*ram < *vcpus*MinRAMPerVCPU || *ram > *vcpus*MaxRAMPerVCPU) {
+		warnings = append(warnings, fmt.Sprintf("%vGB of RAM for %v vCPUs is outside the usual %v-%vGB per vCPU", *ram, *vcpus, MinRAMPerVCPU, MaxRAMPerVCPU))
+	}
+
+	if storage != nil && *storage > 0 && *storage < MinStorage {
+		warnings = append(warnings, fmt.Sprintf("%vGB of storage is less than the usual minimum of %vGB", *storage, MinStorage))
+	}
+
+	return warnings
+}
+
+func validateGPUCount(err *ValidationError, count int) {
+	if count < 1 {
+		err.Add("gpu count must be positive, got %v", count)
+	}
+}
+
+func (req *DeployServerRequest) Validate() error {
+	err := &ValidationError{}
+
+	if req.Name == "" {
+		err.Add("name must not be empty")
+	}
+
+	if req.AdminUser == "" {
+		err.Add("admin user must not be empty")
+	}
+
+	if req.AdminPass == "" {
+		err.Add("admin password must not be empty")
+	}
+
+	switch req.InstanceType {
+	case "gpu":
+		if req.GPUModel == "" {
+			err.Add("gpu model must be set for gpu instances")
+		}
+		validateGPUCount(err, req.GPUCount)
+	case "cpu":
+		if req.CPUModel == "" {
+			err.Add("cpu model must be set for cpu instances")
+		}
+	default:
+		err.Add("instance type must be gpu or cpu, got %q", req.InstanceType)
+	}
+
+	validateResources(err, &req.VCPUs, &req.RAM, &req.Storage)
+
+	if !contains(StorageClasses, req.StorageClass) {
+		err.Add("storage class must be one of %v, got %q", strings.Join(StorageClasses, ", "), req.StorageClass)
+	}
+
+	if req.Location == "" {
+		err.Add("location must not be empty")
+	}
+
+	return err.OrNil()
+}
+
+// Warnings returns problems that may be mistakes but don't stop the API
+// from accepting the request.
+func (req *DeployServerRequest) Warnings() []string {
+	var gpuCount *int
+	if req.InstanceType == "gpu" {
+		gpuCount = &req.GPUCount
+	}
+	return resourceWarnings(gpuCount, &req.VCPUs, &req.RAM, &req.Storage)
+}
+
+func (req *ModifyServerRequest) Validate() error {
+	err := &ValidationError{}
+
+	if req.ServerId == "" {
+		err.Add("server id must not be empty")
+	}
+
+	if req.InstanceType != nil {
+		switch *req.InstanceType {
+		case "gpu":
+			if req.GPUModel == nil || *req.GPUModel == "" {
+				err.Add("gpu model must be set for gpu instances")
+			}
+			if req.GPUCount == nil {
+				err.Add("gpu count must be set for gpu instances")
+			}
+		case "cpu":
+			if req.CPUModel == nil || *req.CPUModel == "" {
+				err.Add("cpu model must be set for cpu instances")
+			}
+		default:
+			err.Add("instance type must be gpu or cpu, got %q", *req.InstanceType)
+		}
+	}
+
+	if req.GPUCount != nil {
+		validateGPUCount(err, *req.GPUCount)
+	}
+
+	validateResources(err, req.VCPUs, req.RAM, req.Storage)
+
+	return err.OrNil()
+}
+
+// Warnings returns problems that may be mistakes but don't stop the API
+// from accepting the request.
+func (req *ModifyServerRequest) Warnings() []string {
+	return resourceWarnings(req.GPUCount, req.VCPUs, req.RAM, req.Storage)
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
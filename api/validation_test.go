@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func validDeployRequest() DeployServerRequest {
+	return DeployServerRequest{
+		AdminUser:    "user",
+		AdminPass:    "secret",
+		InstanceType: "gpu",
+		GPUModel:     "A4000",
+		GPUCount:     1,
+		VCPUs:        4,
+		RAM:          16,
+		Storage:      100,
+		StorageClass: "io1",
+		OS:           "Ubuntu 20.04 LTS",
+		Location:     "na-us-chi-1",
+		Name:         "train-01",
+	}
+}
+
+func TestDeployServerRequestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(req *DeployServerRequest)
+		problems []string
+	}{
+		{"valid gpu", func(req *DeployServerRequest) {}, nil},
+		{"valid cpu", func(req *DeployServerRequest) {
+			req.InstanceType, req.GPUModel, req.GPUCount, req.CPUModel = "cpu", "", 0, "Intel_Xeon_v4"
+		}, nil},
+		{"unusual ram ratio is allowed", func(req *DeployServerRequest) { req.VCPUs, req.RAM = 2, 64 }, nil},
+		{"missing credentials", func(req *DeployServerRequest) { req.Name, req.AdminUser, req.AdminPass = "", "", "" }, []string{
+			"name must not be empty",
+			"admin user must not be empty",
+			"admin password must not be empty",
+		}},
+		{"gpu without model", func(req *DeployServerRequest) { req.GPUModel = "" }, []string{"gpu model must be set"}},
+		{"many gpus are allowed", func(req *DeployServerRequest) { req.GPUCount = 9 }, nil},
+		{"small storage is allowed", func(req *DeployServerRequest) { req.Storage = 10 }, nil},
+		{"gpu count zero", func(req *DeployServerRequest) { req.GPUCount = 0 }, []string{"gpu count must be positive, got 0"}},
+		{"cpu without model", func(req *DeployServerRequest) { req.InstanceType = "cpu" }, []string{"cpu model must be set"}},
+		{"unknown instance type", func(req *DeployServerRequest) { req.InstanceType = "tpu" }, []string{`instance type must be gpu or cpu, got "tpu"`}},
+		{"bad resources", func(req *DeployServerRequest) { req.VCPUs, req.RAM, req.Storage = 0, -1, 0 }, []string{
+			"vcpus must be positive",
+			"ram must be positive",
+			"storage must be positive",
+		}},
+		{"bad storage class", func(req *DeployServerRequest) { req.StorageClass = "ssd" }, []string{"storage class must be one of io1, st1"}},
+		{"missing location", func(req *DeployServerRequest) { req.Location = "" }, []string{"location must not be empty"}},
+	}
+
+	for _, test := range tests {
+		req := validDeployRequest()
+		test.modify(&req)
+
+		err := req.Validate()
+		if len(test.problems) == 0 {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%v: expected a validation error, got %v", test.name, err)
+			continue
+		}
+
+		if len(verr.Problems) != len(test.problems) {
+			t.Errorf("%v: got problems %q, want %v", test.name, verr.Problems, len(test.problems))
+			continue
+		}
+
+		for i, want := range test.problems {
+			if !strings.HasPrefix(verr.Problems[i], want) {
+				t.Errorf("%v: problem %v is %q, want prefix %q", test.name, i, verr.Problems[i], want)
+			}
+		}
+	}
+}
+
+func TestDeployServerRequestWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(req *DeployServerRequest)
+		warnings int
+	}{
+		{"usual request", func(req *DeployServerRequest) {}, 0},
+		{"1GB per vCPU", func(req *DeployServerRequest) { req.VCPUs, req.RAM = 4, 4 }, 0},
+		{"8GB per vCPU", func(req *DeployServerRequest) { req.VCPUs, req.RAM = 4, 32 }, 0},
+		{"too little ram", func(req *DeployServerRequest) { req.VCPUs, req.RAM = 4, 2 }, 1},
+		{"too much ram", func(req *DeployServerRequest) { req.VCPUs, req.RAM = 2, 64 }, 1},
+		{"invalid vcpus", func(req *DeployServerRequest) { req.VCPUs, req.RAM = 0, 64 }, 0},
+		{"many gpus", func(req *DeployServerRequest) { req.GPUCount = 9 }, 1},
+		{"gpu count ignored for cpu", func(req *DeployServerRequest) { req.InstanceType, req.GPUCount = "cpu", 9 }, 0},
+		{"small storage", func(req *DeployServerRequest) { req.Storage = 10 }, 1},
+		{"everything unusual", func(req *DeployServerRequest) { req.GPUCount, req.VCPUs, req.RAM, req.Storage = 16, 2, 64, 10 }, 3},
+	}
+
+	for _, test := range tests {
+		req := validDeployRequest()
+		test.modify(&req)
+
+		if got := req.Warnings(); len(got) != test.warnings {
+			t.Errorf("%v: got warnings %q, want %v", test.name, got, test.warnings)
+		}
+	}
+}
+
+func TestModifyServerRequestValidate(t *testing.T) {
+	gpu, cpu, model, count, vcpus, ram, storage := "gpu", "cpu", "A4000", 2, 4, 16, 100
+	zero := 0
+
+	tests := []struct {
+		name  string
+		req   ModifyServerRequest
+		valid bool
+	}{
+		{"full gpu spec", ModifyServerRequest{ServerId: "s1", InstanceType: &gpu, GPUModel: &model, GPUCount: &count, VCPUs: &vcpus, RAM: &ram, Storage: &storage}, true},
+		{"cpu without model", ModifyServerRequest{ServerId: "s1", InstanceType: &cpu, VCPUs: &vcpus, RAM: &ram, Storage: &storage}, false},
+		{"gpu without count", ModifyServerRequest{ServerId: "s1", InstanceType: &gpu, GPUModel: &model}, false},
+		{"zero gpus", ModifyServerRequest{ServerId: "s1", InstanceType: &gpu, GPUModel: &model, GPUCount: &zero}, false},
+		{"missing server id", ModifyServerRequest{RAM: &ram}, false},
+	}
+
+	for _, test := range tests {
+		err := test.req.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%v: got error %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Show the log of changes made to servers",
+	}
+	auditListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List audit log entries",
+		RunE:  listAudit,
+	}
+)
+
+// auditEntry is one line of the audit log. Profile is the config file in
+// use, since that determines which account the change was made with.
+type auditEntry struct {
+	Time      time.Time         `json:"time"`
+	User      string            `json:"user"`
+	Host      string            `json:"host"`
+	Profile   string            `json:"profile"`
+	Action    string            `json:"action"`
+	Server    string            `json:"server,omitempty"`
+	Params    map[string]string `json:"params"`
+	Success   bool              `json:"success"`
+	Error     string            `json:"error,omitempty"`
+	LatencyMs int64             `json:"latency_ms"`
+}
+
+func init() {
+	auditListCmd.Flags().String("server", "", "Only show entries for this server")
+	auditListCmd.Flags().String("action", "", "Only show entries for this action (deploy, modify, start, stop, restart or delete)")
+	auditListCmd.Flags().String("since", "", "Only show entries newer than this (e.g. 7d, 12h or 2022-06-01)")
+	auditListCmd.Flags().String("output", "table", "Output format (table or json)")
+	auditCmd.AddCommand(auditListCmd)
+	rootCmd.AddCommand(auditCmd)
+}
+
+func auditLogPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.jsonl"), nil
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// auditAction turns an endpoint path such as "stop/single" into an action
+// name.
+func auditAction(path string) string {
+	return strings.SplitN(path, "/", 2)[0]
+}
+
+// recordMutation appends a mutating API call to the audit log and forwards
+// it to the configured sinks. Failures are reported but never fail the
+// command that made the call.
+func recordMutation(mutation api.Mutation) {
+	host, _ := os.Hostname()
+
+	entry := auditEntry{
+		Time:      time.Now().UTC(),
+		User:      currentUser(),
+		Host:      host,
+		Profile:   viper.ConfigFileUsed(),
+		Action:    auditAction(mutation.Path),
+		Server:    mutation.ServerId,
+		Params:    mutation.Params,
+		Success:   mutation.Success,
+		Error:     mutation.Error,
+		LatencyMs: mutation.Latency.Milliseconds(),
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("warning: failed to write audit log: %v", err)
+		return
+	}
+
+	if err := appendAuditLog(line); err != nil {
+		log.Printf("warning: failed to write audit log: %v", err)
+	}
+
+	if url := viper.GetString("audit.webhook"); url != "" {
+		if err := postWebhook(url, entry); err != nil {
+			log.Printf("warning: failed to send audit entry to webhook: %v", err)
+		}
+	}
+
+	if viper.GetBool("audit.syslog") {
+		if err := sendSyslog(string(line)); err != nil {
+			log.Printf("warning: failed to send audit entry to syslog: %v", err)
+		}
+	}
+}
+
+func appendAuditLog(line []byte) error {
+	path, err := auditLogPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// parseSince accepts a number of days such as "7d", a Go duration such as
+// "12h" or a date in YYYY-MM-DD or RFC 3339 format.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected e.g. 7d, 12h or 2022-06-01", value)
+}
+
+func readAuditLog() ([]auditEntry, error) {
+	path, err := auditLogPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []auditEntry{}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", path, n, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func listAudit(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	server, err := flags.GetString("server")
+	if err != nil {
+		return err
+	}
+
+	action, err := flags.GetString("action")
+	if err != nil {
+		return err
+	}
+
+	since, err := flags.GetString("since")
+	if err != nil {
+		return err
+	}
+
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+
+	var after time.Time
+	if since != "" {
+		if after, err = parseSince(since, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	entries, err := readAuditLog()
+	if err != nil {
+		return err
+	}
+
+	filtered := []auditEntry{}
+	for _, entry := range entries {
+		if server != "" && entry.Server != server {
+			continue
+		}
+		if action != "" && entry.Action != action {
+			continue
+		}
+		if entry.Time.Before(after) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+
+	switch output {
+	case "json":
+		return writeJSON(filtered)
+	case "table":
+	default:
+		return fmt.Errorf("invalid output %v, must be table or json", output)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Time", "User", "Action", "Server", "Result", "Latency", "Params"})
+	for _, entry := range filtered {
+		result := "ok"
+		if !entry.Success {
+			result = "failed: " + entry.Error
+		}
+
+		params := []string{}
+		for _, key := range sortedKeys(entry.Params) {
+			if key == "server" || key == "server_id" {
+				continue
+			}
+			params = append(params, fmt.Sprintf("%v=%v", key, entry.Params[key]))
+		}
+
+		t.AppendRow(table.Row{entry.Time.Local().Format("2006-01-02 15:04:05"), entry.User, entry.Action, entry.Server, result, fmt.Sprintf("%vms", entry.LatencyMs), strings.Join(params, " ")})
+	}
+	t.Render()
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows && !plan9
+
+package commands
+
+import "log/syslog"
+
+func sendSyslog(message string) error {
+	writer, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_USER, "tensordock-cli")
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	return writer.Notice(message)
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package commands
+
+import "errors"
+
+func sendSyslog(message string) error {
+	return errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2022, 6, 15, 12, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"7d", now.AddDate(0, 0, -7), false},
+		{"0d", now, false},
+		{"12h", now.Add(-12 * time.Hour), false},
+		{"90m", now.Add(-90 * time.Minute), false},
+		{"1h30m", now.Add(-90 * time.Minute), false},
+		{"2022-06-01", time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local), false},
+		{"2022-06-01T08:00:00Z", time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC), false},
+		{"", time.Time{}, true},
+		{"d", time.Time{}, true},
+		{"7 days", time.Time{}, true},
+		{"xd", time.Time{}, true},
+		{"2022-13-01", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseSince(test.value, now)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseSince(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("parseSince(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
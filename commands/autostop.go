@@ -0,0 +1,360 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	autostopCmd = &cobra.Command{
+		Use:   "autostop [flags] [server_id...]",
+		Short: "Stop servers that run too long or outside allowed hours",
+		Long: `Periodically checks running servers and stops those that violate the policy.
+
+A server is considered for stopping when it has been running longer than
+--maxDuration or when the current time falls outside --allowedHours. If
+--idleProbe is set, the server is only stopped if it also looks idle over
+SSH (GPU utilization, login sessions and load average). The probe uses the
+built-in SSH client with the same host keys and credentials as "servers ssh".
+
+Running time is measured from when the daemon first sees the server running,
+which is remembered across restarts.
+
+Policy flags may also be set in the config file under the "autostop" key.`,
+		RunE: autostop,
+	}
+)
+
+type autostopPolicy struct {
+	MaxDuration   time.Duration
+	AllowedHours  *hourRange
+	IdleProbe     bool
+	GPUThreshold  float64
+	LoadThreshold float64
+	// SSHFlags holds the connection flags used by the idle probe
+	SSHFlags *pflag.FlagSet
+}
+
+type hourRange struct {
+	Start int
+	End   int
+}
+
+type idleReport struct {
+	GPUUtilization float64
+	Sessions       int
+	Load           float64
+}
+
+func init() {
+	flags := autostopCmd.Flags()
+	flags.Duration("interval", 5*time.Minute, "Time between checks")
+	flags.Duration("maxDuration", 0, "Maximum time a server may run before being stopped (0 to disable)")
+	flags.String("allowedHours", "", "Local time window servers may run in (e.g. 08:00-20:00)")
+	flags.Bool("idleProbe", false, "Only stop servers that look idle over SSH")
+	flags.Float64("gpuThreshold", 5, "GPU utilization percentage below which a server is idle")
+	flags.Float64("loadThreshold", 0.5, "1-minute load average below which a server is idle")
+	addSSHFlags(flags)
+	flags.Bool("once", false, "Run a single check and exit")
+
+	rootCmd.AddCommand(autostopCmd)
+}
+
+func autostop(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	// config values fill in flags that weren't given; binding the flags
+	// with viper instead would leak their defaults into the config file
+	for _, name := range []string{"maxDuration", "allowedHours", "idleProbe", "gpuThreshold", "loadThreshold", "user"} {
+		if flags.Changed(name) || !viper.IsSet("autostop."+name) {
+			continue
+		}
+		if err := flags.Set(name, viper.GetString("autostop."+name)); err != nil {
+			return fmt.Errorf("invalid autostop.%v in config file: %v", name, err)
+		}
+	}
+
+	interval, err := flags.GetDuration("interval")
+	if err != nil {
+		return err
+	}
+
+	once, err := flags.GetBool("once")
+	if err != nil {
+		return err
+	}
+
+	policy := autostopPolicy{}
+
+	if policy.MaxDuration, err = flags.GetDuration("maxDuration"); err != nil {
+		return err
+	}
+
+	if policy.IdleProbe, err = flags.GetBool("idleProbe"); err != nil {
+		return err
+	}
+
+	if policy.GPUThreshold, err = flags.GetFloat64("gpuThreshold"); err != nil {
+		return err
+	}
+
+	if policy.LoadThreshold, err = flags.GetFloat64("loadThreshold"); err != nil {
+		return err
+	}
+
+	policy.SSHFlags = flags
+
+	hours, err := flags.GetString("allowedHours")
+	if err != nil {
+		return err
+	}
+
+	if hours != "" {
+		policy.AllowedHours, err = parseHourRange(hours)
+		if err != nil {
+			return err
+		}
+	}
+
+	if policy.MaxDuration <= 0 && policy.AllowedHours == nil {
+		return errors.New("either --maxDuration or --allowedHours must be set")
+	}
+
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+
+	targets := map[string]bool{}
+	for _, id := range args {
+		targets[id] = true
+	}
+
+	// first-seen times are kept in the state directory so that restarting
+	// the daemon doesn't reset how long servers have been running
+	firstSeen := map[string]time.Time{}
+	if err := readState("autostop.json", &firstSeen); err != nil {
+		return err
+	}
+
+	for {
+		err := autostopCheck(policy, targets, firstSeen, time.Now())
+		if saveErr := writeState("autostop.json", firstSeen); saveErr != nil {
+			log.Printf("warning: failed to save autostop state: %v", saveErr)
+		}
+
+		if err != nil {
+			if once {
+				return err
+			}
+			log.Printf("warning: %v", err)
+		}
+
+		if once {
+			return nil
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func autostopCheck(policy autostopPolicy, targets map[string]bool, firstSeen map[string]time.Time, now time.Time) error {
+	servers, err := listServers()
+	if err != nil {
+		return err
+	}
+
+	for id := range firstSeen {
+		if _, ok := servers[id]; !ok {
+			delete(firstSeen, id)
+		}
+	}
+
+	for _, server := range servers {
+		if len(targets) > 0 && !targets[server.Id] {
+			continue
+		}
+
+		if !isRunning(server) {
+			delete(firstSeen, server.Id)
+			continue
+		}
+
+		if _, ok := firstSeen[server.Id]; !ok {
+			firstSeen[server.Id] = now
+		}
+
+		reason := autostopReason(policy, now.Sub(firstSeen[server.Id]), now)
+		if reason == "" {
+			continue
+		}
+
+		if policy.IdleProbe {
+			report, err := probeIdle(policy, server)
+			if err != nil {
+				log.Printf("warning: idle probe for %v failed, skipping: %v", server.Id, err)
+				continue
+			}
+
+			if !report.isIdle(policy) {
+				continue
+			}
+
+			reason = fmt.Sprintf("%v, idle (gpu %.0f%%, %v sessions, load %.2f)", reason, report.GPUUtilization, report.Sessions, report.Load)
+		}
+
+		if err := checkProtected(server.Id, "stop"); err != nil {
+			log.Printf("not stopping %v (%v): %v", server.Id, server.Name, err)
+			continue
+		}
+
+		if client.DryRun {
+			log.Printf("would stop %v (%v): %v", server.Id, server.Name, reason)
+			continue
+		}
+
+		stopRes, err := client.StopServer(server.Id)
+		if err != nil {
+			log.Printf("warning: failed to stop %v: %v", server.Id, err)
+			continue
+		}
+
+		if !stopRes.Success {
+			log.Printf("warning: failed to stop %v: %v", server.Id, stopRes.Error)
+			continue
+		}
+
+		delete(firstSeen, server.Id)
+		log.Printf("stopped %v (%v): %v", server.Id, server.Name, reason)
+	}
+
+	return nil
+}
+
+func autostopReason(policy autostopPolicy, running time.Duration, now time.Time) string {
+	if policy.MaxDuration > 0 && running >= policy.MaxDuration {
+		return fmt.Sprintf("running for %v", running.Truncate(time.Second))
+	}
+
+	if policy.AllowedHours != nil && !policy.AllowedHours.contains(now) {
+		return "outside allowed hours"
+	}
+
+	return ""
+}
+
+func isRunning(server api.Server) bool {
+	return strings.EqualFold(server.Status, "running")
+}
+
+// parseHourRange parses a "HH[:MM]-HH[:MM]" window. Windows where the end
+// is before the start wrap around midnight.
+func parseHourRange(value string) (*hourRange, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid hour range %q", value)
+	}
+
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &hourRange{start, end}, nil
+}
+
+func parseClock(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, ":") {
+		value += ":00"
+	}
+
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (r *hourRange) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if r.Start <= r.End {
+		return minute >= r.Start && minute < r.End
+	}
+	return minute >= r.Start || minute < r.End
+}
+
+func probeIdle(policy autostopPolicy, server api.Server) (*idleReport, error) {
+	target, err := sshTargetFromFlags(policy.SSHFlags, server.Id, server.Ip)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := target.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	out, err := session.Output("nvidia-smi --query-gpu=utilization.gpu --format=csv,noheader,nounits 2>/dev/null; echo ---; who | wc -l; cat /proc/loadavg")
+	if err != nil {
+		return nil, err
+	}
+
+	sections := strings.SplitN(string(out), "---", 2)
+	if len(sections) != 2 {
+		return nil, errors.New("unexpected probe output")
+	}
+
+	report := idleReport{}
+	for _, line := range strings.Fields(sections[0]) {
+		util, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			continue
+		}
+		if util > report.GPUUtilization {
+			report.GPUUtilization = util
+		}
+	}
+
+	fields := strings.Fields(sections[1])
+	if len(fields) < 2 {
+		return nil, errors.New("unexpected probe output")
+	}
+
+	if report.Sessions, err = strconv.Atoi(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if report.Load, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+func (r *idleReport) isIdle(policy autostopPolicy) bool {
+	return r.GPUUtilization < policy.GPUThreshold &&
+		r.Sessions == 0 &&
+		r.Load < policy.LoadThreshold
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHourRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    hourRange
+		wantErr bool
+	}{
+		{"08:00-20:00", hourRange{8 * 60, 20 * 60}, false},
+		{"8-20", hourRange{8 * 60, 20 * 60}, false},
+		{"22:30-06:15", hourRange{22*60 + 30, 6*60 + 15}, false},
+		{" 09:00 - 17:00 ", hourRange{9 * 60, 17 * 60}, false},
+		{"08:00", hourRange{}, true},
+		{"08:00-20:00-22:00", hourRange{}, true},
+		{"25:00-26:00", hourRange{}, true},
+		{"morning-evening", hourRange{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseHourRange(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseHourRange(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && *got != test.want {
+			t.Errorf("parseHourRange(%q) = %v, want %v", test.value, *got, test.want)
+		}
+	}
+}
+
+func TestAutostopReason(t *testing.T) {
+	day := hourRange{8 * 60, 20 * 60}
+	night := hourRange{22 * 60, 6 * 60}
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2022, 6, 15, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name    string
+		policy  autostopPolicy
+		running time.Duration
+		now     time.Time
+		want    string
+	}{
+		{"under max duration", autostopPolicy{MaxDuration: 8 * time.Hour}, 2 * time.Hour, at(12, 0), ""},
+		{"over max duration", autostopPolicy{MaxDuration: 8 * time.Hour}, 9*time.Hour + 500*time.Millisecond, at(12, 0), "running for 9h0m0s"},
+		{"inside allowed hours", autostopPolicy{AllowedHours: &day}, time.Hour, at(19, 59), ""},
+		{"end of allowed hours", autostopPolicy{AllowedHours: &day}, time.Hour, at(20, 0), "outside allowed hours"},
+		{"before allowed hours", autostopPolicy{AllowedHours: &day}, time.Hour, at(7, 59), "outside allowed hours"},
+		{"window across midnight", autostopPolicy{AllowedHours: &night}, time.Hour, at(2, 0), ""},
+		{"outside window across midnight", autostopPolicy{AllowedHours: &night}, time.Hour, at(12, 0), "outside allowed hours"},
+		{"no policy", autostopPolicy{}, 100 * time.Hour, at(3, 0), ""},
+	}
+
+	for _, test := range tests {
+		if got := autostopReason(test.policy, test.running, test.now); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
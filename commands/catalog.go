@@ -0,0 +1,318 @@
+package commands
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	catalogCmd = &cobra.Command{
+		Use:   "catalog",
+		Short: "Show GPU and location details",
+	}
+	catalogGpusCmd = &cobra.Command{
+		Use:   "gpus",
+		Short: "List known GPU models",
+		RunE:  listCatalogGpus,
+	}
+	catalogLocationsCmd = &cobra.Command{
+		Use:   "locations",
+		Short: "List known locations",
+		RunE:  listCatalogLocations,
+	}
+	catalogUpdateCmd = &cobra.Command{
+		Use:     "update",
+		Short:   "Update the local catalog from a URL or file",
+		RunE:    updateCatalog,
+		PostRun: logAction("catalog updated"),
+	}
+)
+
+// The embedded catalog is maintained by hand: GPU specs are NVIDIA's
+// published figures and prices are estimates. Model IDs are not checked
+// against the API except by "catalog gpus".
+//
+//go:embed catalog.json
+var embeddedCatalog []byte
+
+// catalogClient keeps a stalled mirror from hanging "catalog update".
+var catalogClient = &http.Client{Timeout: 30 * time.Second}
+
+const catalogUrl = "https://raw.githubusercontent.com/caguiclajmg/tensordock-cli/main/commands/catalog.json"
+
+type gpuSpec struct {
+	Name        string  `json:"name"`
+	VRAM        int     `json:"vram"`
+	Arch        string  `json:"architecture"`
+	FP32        float64 `json:"fp32_tflops"`
+	FP16        float64 `json:"fp16_tflops"`
+	HourlyPrice float64 `json:"hourly_price"`
+}
+
+type locationSpec struct {
+	Country string `json:"country"`
+	City    string `json:"city"`
+	Region  string `json:"region"`
+}
+
+type catalog struct {
+	GPUs      map[string]gpuSpec `json:"gpus"`
+	Resources struct {
+		VCPU    float64 `json:"vcpu"`
+		RAM     float64 `json:"ram"`
+		Storage float64 `json:"storage"`
+	} `json:"resources"`
+	OS        []string                `json:"os"`
+	Locations map[string]locationSpec `json:"locations"`
+}
+
+var loadedCatalog *catalog
+
+func init() {
+	catalogCmd.AddCommand(catalogGpusCmd)
+	catalogCmd.AddCommand(catalogLocationsCmd)
+	catalogCmd.AddCommand(catalogUpdateCmd)
+	catalogUpdateCmd.Flags().String("url", catalogUrl, "URL to download the catalog from")
+	catalogUpdateCmd.Flags().String("file", "", "Local catalog file to install instead of downloading")
+
+	rootCmd.AddCommand(catalogCmd)
+}
+
+func catalogPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "catalog.json"), nil
+}
+
+// getCatalog returns the catalog installed by "catalog update", falling
+// back to the one embedded in the binary.
+func getCatalog() *catalog {
+	if loadedCatalog != nil {
+		return loadedCatalog
+	}
+
+	loadedCatalog = &catalog{}
+	if err := readState("catalog.json", loadedCatalog); err != nil || len(loadedCatalog.GPUs) == 0 {
+		loadedCatalog = &catalog{}
+		if err := json.Unmarshal(embeddedCatalog, loadedCatalog); err != nil {
+			panic(err)
+		}
+	}
+
+	return loadedCatalog
+}
+
+// gpuHourlyPrice returns the price in USD per GPU-hour. Prices change often,
+// so they can be overridden in the config file under the "pricing" key,
+// e.g. "pricing: {A4000: 0.45}".
+func gpuHourlyPrice(model string) (float64, bool) {
+	if key := "pricing." + model; viper.IsSet(key) {
+		return viper.GetFloat64(key), true
+	}
+
+	spec, ok := getCatalog().GPUs[model]
+	return spec.HourlyPrice, ok && spec.HourlyPrice > 0
+}
+
+// estimateHourlyCost returns the approximate hourly cost of a deploy
+// request while running. Resource prices (per vCPU, per GB of RAM and per GB
+// of storage) can be overridden under "pricing.vcpu", "pricing.ram" and
+// "pricing.storage".
+func estimateHourlyCost(req api.DeployServerRequest) (float64, bool) {
+	resources := getCatalog().Resources
+	price := func(key string, def float64) float64 {
+		if viper.IsSet("pricing." + key) {
+			return viper.GetFloat64("pricing." + key)
+		}
+		return def
+	}
+
+	cost := float64(req.VCPUs)*price("vcpu", resources.VCPU) +
+		float64(req.RAM)*price("ram", resources.RAM) +
+		float64(req.Storage)*price("storage", resources.Storage)
+
+	if req.InstanceType == "gpu" {
+		hourly, ok := gpuHourlyPrice(req.GPUModel)
+		if !ok {
+			return 0, false
+		}
+		cost += hourly * float64(req.GPUCount)
+	}
+
+	return cost, true
+}
+
+func gpuVRAM(model string) (int, bool) {
+	spec, ok := getCatalog().GPUs[model]
+	return spec.VRAM, ok
+}
+
+func locationLabel(location string) string {
+	spec, ok := getCatalog().Locations[location]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%v, %v", spec.City, spec.Country)
+}
+
+func checkCatalogGpu(model string) error {
+	if _, ok := getCatalog().GPUs[model]; !ok {
+		return fmt.Errorf("unknown GPU model %q (see \"tensordock-cli catalog gpus\")", model)
+	}
+	return nil
+}
+
+func checkCatalogLocation(location string) error {
+	if _, ok := getCatalog().Locations[location]; !ok {
+		return fmt.Errorf("unknown location %q (see \"tensordock-cli catalog locations\")", location)
+	}
+	return nil
+}
+
+func checkCatalogOS(os string) error {
+	for _, elem := range getCatalog().OS {
+		if elem == os {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown operating system %q, expected one of %q", os, getCatalog().OS)
+}
+
+// listCatalogGpus shows the catalog next to the model IDs the stock API
+// reports, so that catalog entries the API doesn't know about and API models
+// missing from the catalog stand out.
+func listCatalogGpus(cmd *cobra.Command, args []string) error {
+	gpus := getCatalog().GPUs
+
+	ids := map[string]bool{}
+	for id := range gpus {
+		ids[id] = true
+	}
+
+	listed := map[string]bool{}
+	entries, err := fetchStock("gpu")
+	if err != nil {
+		log.Printf("warning: couldn't check the catalog against the stock API: %v", err)
+	}
+	for _, entry := range entries {
+		listed[entry.Model] = true
+		ids[entry.Model] = true
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Id", "Name", "VRAM", "Architecture", "FP32 TFLOPS", "FP16 TFLOPS", "Price/hr", "In API"})
+	for _, id := range sortedKeys(ids) {
+		inApi := "?"
+		if err == nil {
+			inApi = "no"
+			if listed[id] {
+				inApi = "yes"
+			}
+		}
+
+		elem, ok := gpus[id]
+		if !ok {
+			t.AppendRow(table.Row{id, "(not in catalog)", "", "", "", "", "", inApi})
+			continue
+		}
+
+		price := ""
+		if hourly, ok := gpuHourlyPrice(id); ok {
+			price = fmt.Sprintf("$%.2f", hourly)
+		}
+
+		t.AppendRow(table.Row{id, elem.Name, fmt.Sprintf("%vGB", elem.VRAM), elem.Arch, elem.FP32, elem.FP16, price, inApi})
+	}
+	t.Render()
+
+	return nil
+}
+
+func listCatalogLocations(cmd *cobra.Command, args []string) error {
+	locations := getCatalog().Locations
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Id", "City", "Country", "Region"})
+	for _, id := range sortedKeys(locations) {
+		elem := locations[id]
+		t.AppendRow(table.Row{id, elem.City, elem.Country, elem.Region})
+	}
+	t.Render()
+
+	return nil
+}
+
+func updateCatalog(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	url, err := flags.GetString("url")
+	if err != nil {
+		return err
+	}
+
+	file, err := flags.GetString("file")
+	if err != nil {
+		return err
+	}
+
+	var bytes []byte
+	if file != "" {
+		bytes, err = os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+	} else {
+		res, err := catalogClient.Get(url)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to download catalog: %v", res.Status)
+		}
+
+		bytes, err = io.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	var parsed catalog
+	if err := json.Unmarshal(bytes, &parsed); err != nil {
+		return err
+	}
+
+	if len(parsed.GPUs) == 0 {
+		return errors.New("catalog contains no GPU models")
+	}
+
+	path, err := catalogPath()
+	if err != nil {
+		return err
+	}
+
+	if client.DryRun {
+		log.Printf("would write a catalog of %v GPU model(s) to %v", len(parsed.GPUs), path)
+		return nil
+	}
+
+	return os.WriteFile(path, bytes, 0600)
+}
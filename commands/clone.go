@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone [flags] server_id new_name",
+	Short: "Deploy a new server with the same configuration as an existing one",
+	Long: `Deploys a new server with the instance type, GPU/CPU model and count,
+vCPUs, RAM, storage, storage class and location of an existing server.
+Any of them can be overridden with flags.
+
+The API doesn't report which operating system a server runs, so it is not
+copied: the clone gets --os, which defaults to Ubuntu 20.04 LTS.
+
+With --replicas N, N servers named new_name-1 to new_name-N are deployed
+with the same admin credentials.`,
+	Args:    cobra.ExactArgs(2),
+	RunE:    cloneServer,
+	PostRun: logAction("success"),
+}
+
+func init() {
+	cloneCmd.Flags().String("adminUser", "", "Admin user of the new servers (default: the stored admin user of the source, or ssh.user)")
+	addCloneFlags(cloneCmd.Flags())
+	cloneCmd.Flags().Int("replicas", 1, "Number of servers to deploy")
+	cloneCmd.Flags().Bool("skipValidation", false, "Send the request without client-side validation")
+	addProvisionFlags(cloneCmd.Flags())
+	serversCmd.AddCommand(cloneCmd)
+}
+
+// addCloneFlags registers the flags that override the source server's
+// configuration.
+func addCloneFlags(flags *pflag.FlagSet) {
+	flags.String("instanceType", "", "Either \"gpu\" or \"cpu\" (default: same as the source)")
+	flags.String("gpuModel", "", "The GPU model that you would like to provision (default: same as the source)")
+	flags.Int("gpuCount", 0, "The number of GPUs of the model you specified earlier (default: same as the source)")
+	flags.String("cpuModel", "", "The CPU model that you would like to provision (default: same as the source)")
+	flags.Int("vcpus", 0, "Number of vCPUs that you would like (default: same as the source)")
+	flags.Int("ram", 0, "Number of GB of RAM (default: same as the source)")
+	flags.Int("storage", 0, "Number of GB of networked storage (default: same as the source)")
+	flags.String("storageClass", "", "io1 or st1 (default: same as the source)")
+	flags.String("location", "", "Location (default: same as the source)")
+	flags.String("os", "Ubuntu 20.04 LTS", "Operating system (not copied, the API doesn't report it)")
+}
+
+// cloneAdminUser picks the admin user for clones of a server: the flag if
+// given, then the user its admin password was stored with, then ssh.user.
+func cloneAdminUser(flags *pflag.FlagSet, source string) (string, error) {
+	if flags.Changed("adminUser") {
+		return flags.GetString("adminUser")
+	}
+
+	passwords, err := loadPasswords()
+	if err != nil {
+		return "", err
+	}
+
+	if stored, ok := passwords[source]; ok && stored.AdminUser != "" {
+		return stored.AdminUser, nil
+	}
+
+	if viper.IsSet("ssh.user") {
+		return viper.GetString("ssh.user"), nil
+	}
+
+	return "user", nil
+}
+
+// replicaNames returns the names of the servers to deploy, suffixed with
+// their number if there is more than one.
+func replicaNames(name string, replicas int) []string {
+	if replicas == 1 {
+		return []string{name}
+	}
+
+	names := make([]string, replicas)
+	for i := range names {
+		names[i] = fmt.Sprintf("%v-%v", name, i+1)
+	}
+	return names
+}
+
+// cloneRequest builds the deploy request for a copy of server named name,
+// applying any overrides given as flags.
+func cloneRequest(flags *pflag.FlagSet, server api.Server, name string, adminUser string, adminPass string) (api.DeployServerRequest, error) {
+	os, err := flags.GetString("os")
+	if err != nil {
+		return api.DeployServerRequest{}, err
+	}
+
+	current := currentModifySpec(server)
+
+	req := api.DeployServerRequest{
+		Name:         name,
+		AdminUser:    adminUser,
+		AdminPass:    adminPass,
+		InstanceType: current.InstanceType,
+		GPUModel:     current.GPUModel,
+		GPUCount:     current.GPUCount,
+		CPUModel:     current.CPUModel,
+		VCPUs:        current.VCPUs,
+		RAM:          current.RAM,
+		Storage:      current.Storage,
+		StorageClass: server.StorageClass,
+		OS:           os,
+		Location:     server.Location,
+	}
+
+	stringFlags := map[string]*string{
+		"instanceType": &req.InstanceType,
+		"gpuModel":     &req.GPUModel,
+		"cpuModel":     &req.CPUModel,
+		"storageClass": &req.StorageClass,
+		"location":     &req.Location,
+	}
+	for name, field := range stringFlags {
+		if !flags.Changed(name) {
+			continue
+		}
+		if *field, err = flags.GetString(name); err != nil {
+			return req, err
+		}
+	}
+
+	intFlags := map[string]*int{
+		"gpuCount": &req.GPUCount,
+		"vcpus":    &req.VCPUs,
+		"ram":      &req.RAM,
+		"storage":  &req.Storage,
+	}
+	for name, field := range intFlags {
+		if !flags.Changed(name) {
+			continue
+		}
+		if *field, err = flags.GetInt(name); err != nil {
+			return req, err
+		}
+	}
+
+	switch req.InstanceType {
+	case "cpu":
+		req.GPUModel = ""
+		req.GPUCount = 0
+	case "gpu":
+		req.CPUModel = ""
+	default:
+		return req, errors.New("unknown instance type")
+	}
+
+	return req, nil
+}
+
+func cloneServer(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	source, name := args[0], args[1]
+
+	replicas, err := flags.GetInt("replicas")
+	if err != nil {
+		return err
+	}
+
+	if replicas < 1 {
+		return errors.New("replicas must be at least 1")
+	}
+
+	res, err := client.GetServer(source)
+	if err != nil {
+		return err
+	}
+
+	if !res.Success {
+		return errors.New(res.Error)
+	}
+
+	adminUser, err := cloneAdminUser(flags, source)
+	if err != nil {
+		return err
+	}
+
+	adminPass, generated, err := resolveAdminPass(flags, "", true)
+	if err != nil {
+		return err
+	}
+
+	// check the keys up front so a typo doesn't surface after deploying
+	sshKeys, err := flags.GetStringSlice("sshKey")
+	if err != nil {
+		return err
+	}
+
+	if _, err := readPublicKeys(sshKeys); err != nil {
+		return err
+	}
+
+	if _, err := bootstrapScripts(flags); err != nil {
+		return err
+	}
+
+	names := replicaNames(name, replicas)
+
+	req, err := cloneRequest(flags, res.Server, names[0], adminUser, adminPass)
+	if err != nil {
+		return err
+	}
+
+	skipValidation, err := flags.GetBool("skipValidation")
+	if err != nil {
+		return err
+	}
+
+	if !skipValidation {
+		model := req.GPUModel
+		need := replicas * req.GPUCount
+		if req.InstanceType == "cpu" {
+			model = req.CPUModel
+			need = replicas
+		}
+
+		if err := validateDeployRequest(req, []string{model}, []string{req.Location}, false); err != nil {
+			return err
+		}
+
+		if err := checkStockAvailable(req.InstanceType, model, req.Location, need); err != nil {
+			return err
+		}
+	}
+
+	storePassword, err := flags.GetBool("storePassword")
+	if err != nil {
+		return err
+	}
+
+	// every replica shares the generated password, so print it once up front
+	// instead of with the first server, which might fail to deploy; a stored
+	// password is only printed by finishDeploy if storing it fails
+	if generated && !storePassword && !client.DryRun {
+		log.Printf("admin password: %v", adminPass)
+		generated = false
+	}
+
+	deployed := 0
+	for _, name := range names {
+		req.Name = name
+
+		id, err := submitDeploy(req)
+		if err == nil {
+			deployed++
+			err = finishDeploy(cmd, req, id, generated)
+		}
+
+		if err != nil {
+			if len(names) > 1 {
+				return fmt.Errorf("%v: %v (%v of %v servers were deployed)", name, err, deployed, len(names))
+			}
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/spf13/pflag"
+)
+
+func TestReplicaNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int
+		want     []string
+	}{
+		{"train", 1, []string{"train"}},
+		{"train", 2, []string{"train-1", "train-2"}},
+		{"eval-box", 3, []string{"eval-box-1", "eval-box-2", "eval-box-3"}},
+	}
+
+	for _, test := range tests {
+		if got := replicaNames(test.name, test.replicas); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("replicaNames(%q, %v) = %v, want %v", test.name, test.replicas, got, test.want)
+		}
+	}
+}
+
+func TestCloneRequest(t *testing.T) {
+	gpu := api.Server{Type: "gpu", GPUModel: "A4000", GPUCount: 2, VCPUs: 8, Ram: 32, Storage: 100, StorageClass: "io1", Location: "na-us-chi-1"}
+	cpu := api.Server{Type: "cpu", CPUModel: "Intel_Xeon_v4", VCPUs: 4, Ram: 8, Storage: 20, StorageClass: "st1", Location: "eu-de-1"}
+
+	base := func(server api.Server) api.DeployServerRequest {
+		return api.DeployServerRequest{
+			Name:         "copy",
+			AdminUser:    "admin",
+			AdminPass:    "secret",
+			InstanceType: server.Type,
+			GPUModel:     server.GPUModel,
+			GPUCount:     server.GPUCount,
+			CPUModel:     server.CPUModel,
+			VCPUs:        server.VCPUs,
+			RAM:          server.Ram,
+			Storage:      server.Storage,
+			StorageClass: server.StorageClass,
+			OS:           "Ubuntu 20.04 LTS",
+			Location:     server.Location,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		server  api.Server
+		args    []string
+		modify  func(req *api.DeployServerRequest)
+		wantErr bool
+	}{
+		{"copy of a gpu server", gpu, nil, func(req *api.DeployServerRequest) {}, false},
+		{"copy of a cpu server", cpu, nil, func(req *api.DeployServerRequest) {}, false},
+		{"overrides", gpu, []string{"--gpuModel", "A5000", "--gpuCount", "1", "--ram", "64", "--location", "eu-de-1", "--os", "Ubuntu 22.04 LTS"}, func(req *api.DeployServerRequest) {
+			req.GPUModel, req.GPUCount, req.RAM, req.Location, req.OS = "A5000", 1, 64, "eu-de-1", "Ubuntu 22.04 LTS"
+		}, false},
+		{"zero override", gpu, []string{"--storage", "0"}, func(req *api.DeployServerRequest) { req.Storage = 0 }, false},
+		{"gpu to cpu", gpu, []string{"--instanceType", "cpu", "--cpuModel", "Intel_Xeon_v4"}, func(req *api.DeployServerRequest) {
+			req.InstanceType, req.CPUModel, req.GPUModel, req.GPUCount = "cpu", "Intel_Xeon_v4", "", 0
+		}, false},
+		{"cpu to gpu", cpu, []string{"--instanceType", "gpu", "--gpuModel", "A4000", "--gpuCount", "1"}, func(req *api.DeployServerRequest) {
+			req.InstanceType, req.GPUModel, req.GPUCount, req.CPUModel = "gpu", "A4000", 1, ""
+		}, false},
+		{"missing type", api.Server{GPUModel: "A4000", GPUCount: 1}, nil, func(req *api.DeployServerRequest) {
+			req.InstanceType, req.GPUModel, req.GPUCount = "gpu", "A4000", 1
+		}, false},
+		{"unknown type", gpu, []string{"--instanceType", "tpu"}, nil, true},
+	}
+
+	for _, test := range tests {
+		flags := pflag.NewFlagSet("clone", pflag.ContinueOnError)
+		addCloneFlags(flags)
+		if err := flags.Parse(test.args); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		got, err := cloneRequest(flags, test.server, "copy", "admin", "secret")
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: error = %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+
+		want := base(test.server)
+		test.modify(&want)
+		if got != want {
+			t.Errorf("%v: got %+v, want %+v", test.name, got, want)
+		}
+	}
+}
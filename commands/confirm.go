@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func addConfirmFlags(flags *pflag.FlagSet) {
+	flags.Bool("yes", false, "Don't ask for confirmation")
+}
+
+// serverSpec summarizes a server's hardware on one line.
+func serverSpec(server api.Server) string {
+	parts := []string{}
+	if server.GPUCount > 0 {
+		parts = append(parts, fmt.Sprintf("%vx %v", server.GPUCount, server.GPUModel))
+	} else if server.CPUModel != "" {
+		parts = append(parts, server.CPUModel)
+	}
+	parts = append(parts,
+		fmt.Sprintf("%v vCPUs", server.VCPUs),
+		fmt.Sprintf("%vGB RAM", server.Ram),
+		strings.TrimSpace(fmt.Sprintf("%vGB %v", server.Storage, server.StorageClass)))
+	return strings.Join(parts, ", ")
+}
+
+// confirmServerAction shows what is about to happen to a server and asks
+// for confirmation, unless --yes or --dryRun was given. extra rows are
+// appended to the summary, e.g. the requested changes.
+func confirmServerAction(cmd *cobra.Command, action string, id string, extra ...table.Row) error {
+	if client.DryRun {
+		return nil
+	}
+
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+
+	if yes {
+		return nil
+	}
+
+	if !isTerminal() {
+		return fmt.Errorf("refusing to %v %v without confirmation, pass --yes to skip it", action, id)
+	}
+
+	res, err := client.GetServer(id)
+	if err != nil {
+		return err
+	}
+
+	if !res.Success {
+		return errors.New(res.Error)
+	}
+
+	server := res.Server
+	if server.Id == "" {
+		server.Id = id
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendRows([]table.Row{
+		{"Name", server.Name},
+		{"ID", server.Id},
+		{"Location", server.Location},
+		{"Status", server.Status},
+		{"Spec", serverSpec(server)},
+		{"Cost", fmt.Sprintf("$%v/hr running, $%v/hr stopped, $%v charged so far", server.Cost.HourOn, server.Cost.HourOff, server.Cost.Charged)},
+	})
+	t.AppendRows(extra)
+	t.Render()
+
+	ok, err := promptConfirm(fmt.Sprintf("%v%v %v?", strings.ToUpper(action[:1]), action[1:], serverLabel(server)))
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("cancelled")
+	}
+
+	return nil
+}
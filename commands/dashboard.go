@@ -0,0 +1,576 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/pkg/browser"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+var (
+	dashboardCmd = &cobra.Command{
+		Use:   "dashboard",
+		Short: "Show a live dashboard of servers, billing and stock",
+		Long: `Shows servers, balance and stock in a full-screen view that refreshes
+periodically.
+
+Keys:
+  up/down, j/k  select a server
+  enter         open an SSH session to the selected server
+  m             open the management panel in a browser
+  s             start the selected server
+  x             stop the selected server
+  R             restart the selected server
+  D             delete the selected server
+  tab           switch between GPU and CPU stock
+  r             refresh now
+  q             quit
+
+--dryRun is not supported.`,
+		RunE: runDashboard,
+	}
+)
+
+type dashboardData struct {
+	Servers []api.Server
+	Billing *api.BillingDetails
+	Stock   []stockEntry
+	Err     error
+	Updated time.Time
+}
+
+type dashboardAction struct {
+	Prompt string
+	Run    func() error
+	Done   string
+}
+
+type dashboard struct {
+	data      dashboardData
+	selected  string
+	stockType string
+	message   string
+	pending   *dashboardAction
+	loading   bool
+}
+
+func init() {
+	dashboardCmd.Flags().Duration("interval", 30*time.Second, "Time between refreshes")
+	dashboardCmd.Flags().String("bin", "ssh", "Name of SSH client executable used with enter (config: ssh.bin)")
+	dashboardCmd.Flags().Bool("native", false, "Use the built-in SSH client with enter (config: ssh.native)")
+	addSSHFlags(dashboardCmd.Flags())
+	rootCmd.AddCommand(dashboardCmd)
+}
+
+func fetchDashboard(stockType string) dashboardData {
+	data := dashboardData{Updated: time.Now()}
+
+	servers, err := listServers()
+	if err != nil {
+		data.Err = err
+		return data
+	}
+
+	for _, server := range servers {
+		data.Servers = append(data.Servers, server)
+	}
+
+	sort.Slice(data.Servers, func(i, j int) bool {
+		if data.Servers[i].Name != data.Servers[j].Name {
+			return data.Servers[i].Name < data.Servers[j].Name
+		}
+		return data.Servers[i].Id < data.Servers[j].Id
+	})
+
+	billing, err := client.GetBillingDetails()
+	switch {
+	case err != nil:
+		data.Err = err
+	case !billing.Success:
+		data.Err = errors.New(billing.Error)
+	default:
+		data.Billing = &billing.BillingDetails
+	}
+
+	stock, err := fetchStock(stockType)
+	if err != nil {
+		data.Err = err
+		return data
+	}
+
+	for _, entry := range stock {
+		if entry.AvailableNow > 0 {
+			data.Stock = append(data.Stock, entry)
+		}
+	}
+
+	if err := sortStock(data.Stock, "available"); err != nil {
+		data.Err = err
+	}
+
+	return data
+}
+
+func formatRunway(hours float64) string {
+	if hours >= 48 {
+		return fmt.Sprintf("%.0fd %.0fh", hours/24, float64(int(hours)%24))
+	}
+	return fmt.Sprintf("%.1fh", hours)
+}
+
+func serverHourlyCost(server api.Server) float32 {
+	if isRunning(server) {
+		return server.Cost.HourOn
+	}
+	return server.Cost.HourOff
+}
+
+func (d *dashboard) selectedServer() (api.Server, bool) {
+	for _, server := range d.data.Servers {
+		if server.Id == d.selected {
+			return server, true
+		}
+	}
+	return api.Server{}, false
+}
+
+// move changes the selection by delta rows, keeping it by ID rather than
+// position so refreshes don't jump to another server.
+func (d *dashboard) move(delta int) {
+	if len(d.data.Servers) == 0 {
+		return
+	}
+
+	index := 0
+	for i, server := range d.data.Servers {
+		if server.Id == d.selected {
+			index = i + delta
+		}
+	}
+
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(d.data.Servers) {
+		index = len(d.data.Servers) - 1
+	}
+
+	d.selected = d.data.Servers[index].Id
+}
+
+func (d *dashboard) render(width int, height int) string {
+	lines := []string{}
+
+	header := "TensorDock"
+	if d.data.Billing != nil {
+		header += fmt.Sprintf("  balance $%.2f  spending $%.2f/hr", d.data.Billing.Balance, d.data.Billing.HourlySpendingRate)
+		if d.data.Billing.HourlySpendingRate > 0 {
+			header += "  runway " + formatRunway(float64(d.data.Billing.Balance/d.data.Billing.HourlySpendingRate))
+		}
+	}
+	if !d.data.Updated.IsZero() {
+		header += "  updated " + d.data.Updated.Format("15:04:05")
+	}
+	if d.loading {
+		header += "  refreshing..."
+	}
+	lines = append(lines, text.Bold.Sprint(header), "")
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"Id", "Name", "Location", "GPU", "vCPUs", "RAM", "Status", "$/hr"})
+	for _, server := range d.data.Servers {
+		gpu := ""
+		if server.GPUCount > 0 {
+			gpu = fmt.Sprintf("%vx %v", server.GPUCount, server.GPUModel)
+		}
+		t.AppendRow(table.Row{server.Id, server.Name, server.Location, gpu, server.VCPUs, server.Ram, server.Status, fmt.Sprintf("%.3f", serverHourlyCost(server))})
+	}
+	t.SetRowPainter(func(row table.Row) text.Colors {
+		if len(row) > 0 && row[0] == d.selected {
+			return text.Colors{text.ReverseVideo}
+		}
+		return nil
+	})
+	lines = append(lines, strings.Split(t.Render(), "\n")...)
+
+	// the stock table gets whatever space is left above the footer
+	rows := height - len(lines) - 8
+	if rows > 0 {
+		s := table.NewWriter()
+		s.SetTitle("%v stock", strings.ToUpper(d.stockType))
+		header := table.Row{"Model", "Location", "Available Now"}
+		if d.stockType == "gpu" {
+			header = append(header, "$/hr")
+		}
+		s.AppendHeader(header)
+		for i, entry := range d.data.Stock {
+			if i == rows {
+				break
+			}
+			row := table.Row{entry.Model, locationLabel(entry.Location), entry.AvailableNow}
+			if d.stockType == "gpu" {
+				price := ""
+				if hourly, ok := gpuHourlyPrice(entry.Model); ok {
+					price = fmt.Sprintf("%.2f", hourly)
+				}
+				row = append(row, price)
+			}
+			s.AppendRow(row)
+		}
+		lines = append(lines, "")
+		lines = append(lines, strings.Split(s.Render(), "\n")...)
+	}
+
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+
+	status := d.message
+	if d.data.Err != nil {
+		status = text.FgRed.Sprint("error: " + d.data.Err.Error())
+	}
+	if d.pending != nil {
+		status = text.FgYellow.Sprint(d.pending.Prompt + " [y/N]")
+	}
+	lines = append(lines, status)
+	lines = append(lines, text.Faint.Sprint("enter ssh  m manage  s start  x stop  R restart  D delete  tab stock  r refresh  q quit"))
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+
+	var out strings.Builder
+	out.WriteString("\x1b[H")
+	for i, line := range lines {
+		out.WriteString(text.Trim(line, width))
+		out.WriteString("\x1b[K")
+		if i < len(lines)-1 {
+			out.WriteString("\r\n")
+		}
+	}
+	out.WriteString("\x1b[J")
+
+	return out.String()
+}
+
+// serverAction returns the action for a key, or nil if the key doesn't
+// act on the selected server.
+func (d *dashboard) serverAction(key string, server api.Server) *dashboardAction {
+	label := fmt.Sprintf("%v (%v)", serverLabel(server), server.Id)
+
+	// protected servers are refused before asking for confirmation
+	if key == "x" || key == "D" {
+		action := "stop"
+		if key == "D" {
+			action = "delete"
+		}
+		if err := checkProtected(server.Id, action); err != nil {
+			return &dashboardAction{Run: func() error { return err }}
+		}
+	}
+
+	switch key {
+	case "s":
+		return &dashboardAction{"", func() error { return checkResponse(client.StartServer(server.Id)) }, "started " + label}
+	case "x":
+		return &dashboardAction{fmt.Sprintf("Stop %v?", label), func() error { return checkResponse(client.StopServer(server.Id)) }, "stopped " + label}
+	case "R":
+		return &dashboardAction{fmt.Sprintf("Restart %v?", label), func() error { return checkResponse(client.RestartServer(server.Id)) }, "restarted " + label}
+	case "D":
+		prompt := fmt.Sprintf("Delete %v (%v, $%.3f/hr)? This cannot be undone.", label, server.Location, serverHourlyCost(server))
+		return &dashboardAction{prompt, func() error {
+			if err := checkResponse(client.DeleteServer(server.Id)); err != nil {
+				return err
+			}
+			refreshSSHConfig()
+			return nil
+		}, "deleted " + label}
+	}
+
+	return nil
+}
+
+func checkResponse(res *api.Response, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if !res.Success {
+		return errors.New(res.Error)
+	}
+
+	return nil
+}
+
+func runDashboard(cmd *cobra.Command, args []string) error {
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		return err
+	}
+
+	// dry-run requests are printed to stdout, which the full-screen view
+	// draws over
+	if client.DryRun {
+		return errors.New("dashboard does not support --dryRun")
+	}
+
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return errors.New("dashboard requires a terminal")
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+
+	enter := func() { fmt.Print("\x1b[?1049h\x1b[?25l") }
+	leave := func() { fmt.Print("\x1b[?25h\x1b[?1049l") }
+
+	enter()
+	defer func() {
+		leave()
+		term.Restore(in, state)
+	}()
+
+	// the reader waits for each key to be handled before reading again, so
+	// it doesn't compete with SSH sessions started from the dashboard
+	keys := make(chan string)
+	handled := make(chan struct{})
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- string(buf[:n])
+			<-handled
+		}
+	}()
+
+	d := &dashboard{stockType: "gpu", loading: true}
+	updates := make(chan dashboardData, 1)
+	results := make(chan string, 1)
+
+	refresh := func() {
+		d.loading = true
+		stockType := d.stockType
+		go func() { updates <- fetchDashboard(stockType) }()
+	}
+
+	redraw := func() {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Print(d.render(width, height))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	refresh()
+	redraw()
+
+	for {
+		select {
+		case data := <-updates:
+			d.data, d.loading = data, false
+			if _, ok := d.selectedServer(); !ok {
+				d.move(0)
+			}
+
+		case msg := <-results:
+			d.message = msg
+			refresh()
+
+		case <-ticker.C:
+			if !d.loading {
+				refresh()
+			}
+
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			quit := d.handleKey(key, cmd, results, refresh, func(run func() error) error {
+				leave()
+				term.Restore(in, state)
+				runErr := run()
+				raw, err := term.MakeRaw(in)
+				if err != nil {
+					return err
+				}
+				state = raw
+				enter()
+				return runErr
+			})
+			if quit {
+				return nil
+			}
+			handled <- struct{}{}
+		}
+
+		redraw()
+	}
+}
+
+// handleKey processes a key press and reports whether to quit. suspend runs
+// a function with the terminal restored, for SSH sessions.
+func (d *dashboard) handleKey(key string, cmd *cobra.Command, results chan<- string, refresh func(), suspend func(func() error) error) bool {
+	if d.pending != nil {
+		action := d.pending
+		d.pending = nil
+		if key != "y" && key != "Y" {
+			d.message = "cancelled"
+			return false
+		}
+		d.runAction(action, results)
+		return false
+	}
+
+	switch key {
+	case "q", "\x03":
+		return true
+	case "\x1b[A", "k":
+		d.move(-1)
+		return false
+	case "\x1b[B", "j":
+		d.move(1)
+		return false
+	case "\t":
+		if d.stockType == "gpu" {
+			d.stockType = "cpu"
+		} else {
+			d.stockType = "gpu"
+		}
+		refresh()
+		return false
+	case "r":
+		refresh()
+		return false
+	}
+
+	server, ok := d.selectedServer()
+	if !ok {
+		return false
+	}
+
+	switch key {
+	case "\r", "\n":
+		err := suspend(func() error {
+			fmt.Printf("connecting to %v...\n", serverLabel(server))
+			return dashboardSSH(cmd, server)
+		})
+		if err != nil {
+			d.message = text.FgRed.Sprint(err.Error())
+		}
+		refresh()
+		return false
+	case "m":
+		if err := browser.OpenURL(server.Links["dashboard"]["href"]); err != nil {
+			d.message = text.FgRed.Sprint(err.Error())
+		}
+		return false
+	}
+
+	if action := d.serverAction(key, server); action != nil {
+		if action.Prompt != "" {
+			d.pending = action
+		} else {
+			d.runAction(action, results)
+		}
+	}
+
+	return false
+}
+
+func (d *dashboard) runAction(action *dashboardAction, results chan<- string) {
+	d.message = "working..."
+	go func() {
+		if err := action.Run(); err != nil {
+			results <- text.FgRed.Sprint(err.Error())
+			return
+		}
+		results <- action.Done
+	}()
+}
+
+// dashboardSSH opens a shell on the server like "servers ssh" does. The
+// remote shell's exit status is not an error, but failing to connect is.
+func dashboardSSH(cmd *cobra.Command, server api.Server) error {
+	flags := cmd.Flags()
+
+	if server.Ip == "" {
+		return fmt.Errorf("server %v has no IP address", server.Id)
+	}
+
+	target, err := sshTargetFromFlags(flags, server.Id, server.Ip)
+	if err != nil {
+		return err
+	}
+
+	native, err := flags.GetBool("native")
+	if err != nil {
+		return err
+	}
+
+	if !flags.Changed("native") {
+		native = viper.GetBool("ssh.native")
+	}
+
+	bin := sshSetting(flags, "bin")
+
+	if native {
+		conn, err := target.dial()
+		if err == nil {
+			defer conn.Close()
+
+			var exitErr *ssh.ExitError
+			if err := runNativeSSH(conn, nil); err != nil && !errors.As(err, &exitErr) {
+				return err
+			}
+			return nil
+		}
+
+		// a changed host key must never be bypassed by retrying
+		// with a client that may not know about it
+		var changed *hostKeyChangedError
+		if errors.As(err, &changed) {
+			return err
+		}
+
+		log.Printf("warning: built-in client failed (%v), falling back to %v", err, bin)
+	}
+
+	err = runExternalSSH(bin, target, nil, nil)
+
+	// OpenSSH exits with 255 when it can't connect, any other status is
+	// the remote shell's
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() != 255 {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("%v: %v", bin, err)
+	}
+
+	return nil
+}
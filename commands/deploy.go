@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+type placement struct {
+	Model     string
+	Location  string
+	Available int
+	Price     float64
+}
+
+// placementConstraints narrows down automatic placement. A Location of
+// "auto" allows any location starting with RegionPrefix and Models lists the
+// acceptable models, e.g. from "any-of:A4000,A5000".
+type placementConstraints struct {
+	Models       []string
+	Location     string
+	RegionPrefix string
+	MinVRAM      int
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// deployWhenAvailable polls stock until one of the model/location candidates
+// has enough units and deploys there. Candidates are tried in order of
+// preference, models first.
+func deployWhenAvailable(req api.DeployServerRequest, models []string, locations []string, interval time.Duration, timeout time.Duration) (string, error) {
+	if len(models) == 0 || len(locations) == 0 {
+		return "", errors.New("at least one model and location must be given")
+	}
+
+	if interval <= 0 {
+		return "", errors.New("interval must be positive")
+	}
+
+	need := 1
+	if req.InstanceType == "gpu" {
+		need = req.GPUCount
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	log.Printf("waiting for %v x%v in %v", strings.Join(models, "|"), need, strings.Join(locations, "|"))
+
+	for {
+		entries, err := fetchStock(req.InstanceType)
+		if err != nil {
+			log.Printf("warning: %v", err)
+		}
+
+		available := map[string]int{}
+		for _, entry := range entries {
+			available[entry.Model+"/"+entry.Location] = entry.AvailableNow
+		}
+
+		for _, model := range models {
+			for _, location := range locations {
+				if available[model+"/"+location] < need {
+					continue
+				}
+
+				candidate := req
+				candidate.Location = location
+				if req.InstanceType == "gpu" {
+					candidate.GPUModel = model
+				} else {
+					candidate.CPUModel = model
+				}
+
+				log.Printf("deploying %v in %v", model, location)
+
+				res, err := client.DeployServer(candidate)
+				if err != nil {
+					return "", err
+				}
+
+				// the stock can run out between polling and deploying,
+				// anything else (bad credentials, low balance, invalid
+				// spec) won't go away by retrying
+				if !res.Success {
+					if !isOutOfStock(res.Error) {
+						return "", errors.New(res.Error)
+					}
+					log.Printf("warning: deploy failed: %v", res.Error)
+					continue
+				}
+
+				return res.Server.Id, nil
+			}
+		}
+
+		if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+			return "", errors.New("timed out waiting for stock")
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// outOfStockErrors are the deploy errors that mean the requested hardware
+// ran out, such as "Not enough stock available". They are matched exactly
+// rather than by loose hints like "unavailable" so that unrelated errors
+// are never retried.
+var outOfStockErrors = []string{
+	"not enough stock",
+	"out of stock",
+	"insufficient stock",
+	"no stock available",
+}
+
+// isOutOfStock reports whether a deploy error means that the requested
+// hardware is no longer available.
+func isOutOfStock(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, known := range outOfStockErrors {
+		if strings.Contains(msg, known) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAutoPlacement(location string, model string) bool {
+	return location == "auto" || strings.HasPrefix(model, "any-of:")
+}
+
+func parseModelChoice(model string) []string {
+	return splitList(strings.TrimPrefix(model, "any-of:"))
+}
+
+// rankPlacements returns the in-stock model/location pairs meeting the
+// constraints, cheapest first. GPU pairs are ranked by total hourly price,
+// CPU pairs (which have no pricing) by availability.
+func rankPlacements(entries []stockEntry, instanceType string, need int, constraints placementConstraints) []placement {
+	accepted := map[string]bool{}
+	for _, model := range constraints.Models {
+		accepted[model] = true
+	}
+
+	candidates := []placement{}
+	for _, entry := range entries {
+		if !accepted[entry.Model] || entry.AvailableNow < need {
+			continue
+		}
+
+		if constraints.Location != "auto" && entry.Location != constraints.Location {
+			continue
+		}
+
+		if !strings.HasPrefix(entry.Location, constraints.RegionPrefix) {
+			continue
+		}
+
+		price := math.Inf(1)
+		if instanceType == "gpu" {
+			if constraints.MinVRAM > 0 {
+				if vram, ok := gpuVRAM(entry.Model); !ok || vram < constraints.MinVRAM {
+					continue
+				}
+			}
+
+			if hourly, ok := gpuHourlyPrice(entry.Model); ok {
+				price = hourly * float64(need)
+			}
+		}
+
+		candidates = append(candidates, placement{entry.Model, entry.Location, entry.AvailableNow, price})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Price != candidates[j].Price {
+			return candidates[i].Price < candidates[j].Price
+		}
+		return candidates[i].Available > candidates[j].Available
+	})
+
+	return candidates
+}
+
+// choosePlacement shows the candidates for automatic placement, asks for
+// confirmation of the cheapest one unless --yes or --dryRun was given, and
+// updates the request with it.
+func choosePlacement(cmd *cobra.Command, req *api.DeployServerRequest, constraints placementConstraints) error {
+	entries, err := fetchStock(req.InstanceType)
+	if err != nil {
+		return err
+	}
+
+	need := 1
+	if req.InstanceType == "gpu" {
+		need = req.GPUCount
+	}
+
+	candidates := rankPlacements(entries, req.InstanceType, need, constraints)
+	if len(candidates) == 0 {
+		return errors.New("no in-stock model/location matches the placement constraints")
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"", "Model", "Location", "Available Now", "Price/hr"})
+	for i, elem := range candidates {
+		marker := ""
+		if i == 0 {
+			marker = "*"
+		}
+
+		t.AppendRow(table.Row{marker, elem.Model, elem.Location, elem.Available, elem.price()})
+	}
+	t.Render()
+
+	chosen := candidates[0]
+
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+
+	if !yes && !client.DryRun {
+		if !isTerminal() {
+			return errors.New("refusing to deploy an automatically chosen placement without confirmation, pass --yes to skip it")
+		}
+
+		ok, err := promptConfirm(fmt.Sprintf("Deploy %vx %v in %v for %v/hr?", need, chosen.Model, chosen.Location, chosen.price()))
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return errors.New("cancelled")
+		}
+	}
+
+	log.Printf("selected %v in %v", chosen.Model, chosen.Location)
+
+	req.Location = chosen.Location
+	if req.InstanceType == "gpu" {
+		req.GPUModel = chosen.Model
+	} else {
+		req.CPUModel = chosen.Model
+	}
+
+	return nil
+}
+
+func (p placement) price() string {
+	if math.IsInf(p.Price, 1) {
+		return "unknown"
+	}
+	return fmt.Sprintf("$%.2f", p.Price)
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsOutOfStock(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"Not enough stock available", true},
+		{"not enough stock available for A4000 in na-us-chi-1", true},
+		{"GPU model is out of stock", true},
+		{"Insufficient stock for the requested GPU count", true},
+		{"No stock available at this location", true},
+		{"Insufficient balance", false},
+		{"Invalid API key or token", false},
+		{"Service temporarily unavailable", false},
+		{"API unavailable, try again later", false},
+		{"Location is not available", false},
+		{"Invalid storage class", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := isOutOfStock(test.msg); got != test.want {
+			t.Errorf("isOutOfStock(%q) = %v, want %v", test.msg, got, test.want)
+		}
+	}
+}
+
+func TestRankPlacements(t *testing.T) {
+	saved := loadedCatalog
+	defer func() { loadedCatalog = saved }()
+
+	loadedCatalog = &catalog{GPUs: map[string]gpuSpec{
+		"A4000": {VRAM: 16, HourlyPrice: 0.40},
+		"A5000": {VRAM: 24, HourlyPrice: 0.54},
+		"A6000": {VRAM: 48},
+	}}
+
+	entries := []stockEntry{
+		{"A4000", "eu-de-1", 1, 0},
+		{"A4000", "na-us-chi-1", 4, 0},
+		{"A4000", "na-us-nyc-1", 8, 0},
+		{"A5000", "na-us-nyc-1", 2, 0},
+		{"A6000", "na-us-chi-1", 6, 0},
+		{"V100", "na-us-chi-1", 9, 0},
+	}
+
+	tests := []struct {
+		name         string
+		instanceType string
+		need         int
+		constraints  placementConstraints
+		want         []string
+	}{
+		{"cheapest first, most available on ties", "gpu", 1, placementConstraints{Models: []string{"A4000", "A5000"}, Location: "auto"}, []string{
+			"A4000@na-us-nyc-1", "A4000@na-us-chi-1", "A4000@eu-de-1", "A5000@na-us-nyc-1",
+		}},
+		{"not enough units", "gpu", 2, placementConstraints{Models: []string{"A4000", "A5000"}, Location: "auto"}, []string{
+			"A4000@na-us-nyc-1", "A4000@na-us-chi-1", "A5000@na-us-nyc-1",
+		}},
+		{"region prefix", "gpu", 1, placementConstraints{Models: []string{"A4000"}, Location: "auto", RegionPrefix: "eu-"}, []string{
+			"A4000@eu-de-1",
+		}},
+		{"fixed location", "gpu", 1, placementConstraints{Models: []string{"A4000", "A5000"}, Location: "na-us-nyc-1"}, []string{
+			"A4000@na-us-nyc-1", "A5000@na-us-nyc-1",
+		}},
+		{"minimum vram", "gpu", 1, placementConstraints{Models: []string{"A4000", "A5000", "V100"}, Location: "auto", MinVRAM: 20}, []string{
+			"A5000@na-us-nyc-1",
+		}},
+		{"unknown price last", "gpu", 1, placementConstraints{Models: []string{"A6000", "A5000"}, Location: "auto"}, []string{
+			"A5000@na-us-nyc-1", "A6000@na-us-chi-1",
+		}},
+		{"cpu by availability", "cpu", 1, placementConstraints{Models: []string{"A4000", "V100"}, Location: "auto"}, []string{
+			"V100@na-us-chi-1", "A4000@na-us-nyc-1", "A4000@na-us-chi-1", "A4000@eu-de-1",
+		}},
+		{"nothing matches", "gpu", 1, placementConstraints{Models: []string{"A100"}, Location: "auto"}, nil},
+	}
+
+	for _, test := range tests {
+		got := []string{}
+		for _, candidate := range rankPlacements(entries, test.instanceType, test.need, test.constraints) {
+			got = append(got, candidate.Model+"@"+candidate.Location)
+		}
+
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
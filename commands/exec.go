@@ -0,0 +1,322 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	execCmd = &cobra.Command{
+		Use:   "exec [flags] [server_id...] -- command...",
+		Short: "Run a command on many servers",
+		Long: `Runs a command over SSH on the given servers and/or those matching --selector,
+concurrently, then prints a summary of exit statuses. Servers that are not
+running are skipped.
+
+Output lines are prefixed with the server name, or grouped per server with
+--group.`,
+		RunE: execServers,
+	}
+)
+
+type execResult struct {
+	Server     api.Server
+	ExitStatus int
+	Err        error
+	Duration   time.Duration
+	Output     lockedBuffer
+}
+
+// lockedBuffer is a buffer that is safe to use as both stdout and stderr of
+// a session, which are copied from separate goroutines.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// prefixWriter prefixes every complete line with a label before writing it
+// to the shared output, so lines from concurrent servers don't interleave.
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.mu.Lock()
+		fmt.Fprintf(w.out, "%v %s\n", w.prefix, w.buf[:i])
+		w.mu.Unlock()
+
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.Write([]byte("\n"))
+	}
+}
+
+func init() {
+	execCmd.Flags().String("selector", "", "Run on servers matching key=value pairs (e.g. gpu=A4000,location=na-us-*)")
+	execCmd.Flags().Int("parallel", 10, "Maximum number of servers to run on at once")
+	execCmd.Flags().Duration("timeout", 0, "Maximum time the command may run on each server (0 for no limit)")
+	execCmd.Flags().Bool("group", false, "Print each server's output together once it finishes instead of prefixing lines")
+	addSSHFlags(execCmd.Flags())
+	serversCmd.AddCommand(execCmd)
+}
+
+func execServers(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	dash := cmd.ArgsLenAtDash()
+	if dash < 0 || dash == len(args) {
+		return errors.New("a command must be given after --")
+	}
+
+	ids, command := args[:dash], strings.Join(args[dash:], " ")
+
+	sel, err := flags.GetString("selector")
+	if err != nil {
+		return err
+	}
+
+	parallel, err := flags.GetInt("parallel")
+	if err != nil {
+		return err
+	}
+
+	timeout, err := flags.GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+
+	group, err := flags.GetBool("group")
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 && sel == "" {
+		return errors.New("at least one server_id or --selector must be given")
+	}
+
+	if parallel < 1 {
+		return errors.New("parallel must be at least 1")
+	}
+
+	targets, err := selectServers(ids, sel)
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		return errors.New("no running servers matched")
+	}
+
+	results := make([]*execResult, len(targets))
+	sem := make(chan struct{}, parallel)
+	mu := &sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for i, server := range targets {
+		wg.Add(1)
+		go func(i int, server api.Server) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := &execResult{Server: server}
+			results[i] = result
+
+			var stdout, stderr io.Writer
+			if group {
+				stdout, stderr = &result.Output, &result.Output
+			} else {
+				label := fmt.Sprintf("[%v]", serverLabel(server))
+				out := &prefixWriter{prefix: label, out: os.Stdout, mu: mu}
+				errOut := &prefixWriter{prefix: label, out: os.Stderr, mu: mu}
+				defer out.Flush()
+				defer errOut.Flush()
+				stdout, stderr = out, errOut
+			}
+
+			start := time.Now()
+			result.Err = execOnServer(cmd, server, command, stdout, stderr, timeout)
+			result.Duration = time.Since(start)
+
+			var exitErr *ssh.ExitError
+			switch {
+			case result.Err == nil:
+			case errors.As(result.Err, &exitErr):
+				result.ExitStatus = exitErr.ExitStatus()
+				result.Err = nil
+			default:
+				result.ExitStatus = -1
+			}
+
+			if group {
+				mu.Lock()
+				fmt.Printf("==> %v <==\n%v\n", serverLabel(server), result.Output.String())
+				mu.Unlock()
+			}
+		}(i, server)
+	}
+
+	wg.Wait()
+
+	failed := 0
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Id", "Name", "Exit Status", "Duration", "Error"})
+	for _, elem := range results {
+		msg := ""
+		if elem.Err != nil {
+			msg = elem.Err.Error()
+		}
+
+		if elem.ExitStatus != 0 {
+			failed++
+		}
+
+		t.AppendRow(table.Row{elem.Server.Id, elem.Server.Name, elem.ExitStatus, elem.Duration.Truncate(time.Millisecond), msg})
+	}
+	t.Render()
+
+	if failed > 0 {
+		return fmt.Errorf("command failed on %v of %v servers", failed, len(results))
+	}
+
+	return nil
+}
+
+// selectServers returns the running servers that were named explicitly or
+// match the selector, sorted by name.
+func selectServers(ids []string, sel string) ([]api.Server, error) {
+	parsed, err := parseSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := listServers()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]api.Server{}
+	for _, id := range ids {
+		server, ok := servers[id]
+		if !ok {
+			return nil, fmt.Errorf("server %v not found", id)
+		}
+		selected[id] = server
+	}
+
+	if sel != "" {
+		for id, server := range servers {
+			if parsed.matches(server) {
+				selected[id] = server
+			}
+		}
+	}
+
+	targets := []api.Server{}
+	for _, server := range selected {
+		if !isRunning(server) {
+			fmt.Fprintf(os.Stderr, "skipping %v: %v\n", serverLabel(server), server.Status)
+			continue
+		}
+		targets = append(targets, server)
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Name != targets[j].Name {
+			return targets[i].Name < targets[j].Name
+		}
+		return targets[i].Id < targets[j].Id
+	})
+
+	return targets, nil
+}
+
+func serverLabel(server api.Server) string {
+	if server.Name != "" {
+		return server.Name
+	}
+	return server.Id
+}
+
+func execOnServer(cmd *cobra.Command, server api.Server, command string, stdout io.Writer, stderr io.Writer, timeout time.Duration) error {
+	target, err := sshTargetFromFlags(cmd.Flags(), server.Id, server.Ip)
+	if err != nil {
+		return err
+	}
+
+	conn, err := target.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if err := session.Start(command); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+
+	if timeout <= 0 {
+		return <-done
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		conn.Close()
+		// wait for the session to finish writing its output
+		<-done
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
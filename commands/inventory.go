@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	inventoryCmd = &cobra.Command{
+		Use:   "inventory",
+		Short: "Export servers as an inventory for Ansible, pdsh, clush or /etc/hosts",
+		Long: `Exports servers that have an IP address, grouped by location, GPU model,
+instance type and status. Host names match the aliases written by
+"ssh-config".
+
+With --format ansible this implements the dynamic inventory protocol, so a
+script that runs "tensordock-cli inventory --format ansible \"$@\"" can be
+passed to ansible -i.`,
+		RunE: exportInventory,
+	}
+
+	nonGroupChars = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+type inventoryHost struct {
+	Alias  string
+	Server api.Server
+	Target *sshTarget
+}
+
+func init() {
+	inventoryCmd.Flags().String("format", "ansible", "Output format (ansible|hosts|pdsh|clush)")
+	inventoryCmd.Flags().Bool("list", false, "Print the whole inventory (ansible)")
+	inventoryCmd.Flags().String("host", "", "Print the variables of a single host (ansible)")
+	inventoryCmd.Flags().String("selector", "", "Only include servers matching key=value pairs (e.g. gpu=A4000,location=na-us-*)")
+	inventoryCmd.Flags().String("prefix", "", "Prefix for host names (config: sshConfig.prefix)")
+	addSSHFlags(inventoryCmd.Flags())
+	rootCmd.AddCommand(inventoryCmd)
+}
+
+func groupName(kind string, value string) string {
+	return kind + "_" + strings.Trim(nonGroupChars.ReplaceAllString(strings.ToLower(value), "_"), "_")
+}
+
+// inventoryGroups returns the groups a server belongs to.
+func inventoryGroups(server api.Server) []string {
+	groups := []string{}
+	if server.Location != "" {
+		groups = append(groups, groupName("location", server.Location))
+	}
+	if server.GPUModel != "" {
+		groups = append(groups, groupName("gpu", server.GPUModel))
+	}
+	if server.Type != "" {
+		groups = append(groups, groupName("type", server.Type))
+	}
+	if server.Status != "" {
+		groups = append(groups, groupName("status", server.Status))
+	}
+	return groups
+}
+
+func inventoryHosts(flags *pflag.FlagSet) ([]inventoryHost, error) {
+	sel, err := flags.GetString("selector")
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := listServers()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []api.Server{}
+	for _, server := range servers {
+		if server.Ip != "" && parsed.matches(server) {
+			selected = append(selected, server)
+		}
+	}
+
+	aliases := hostAliases(selected, sshConfigSetting(flags, "prefix"))
+
+	hosts := []inventoryHost{}
+	for _, server := range selected {
+		target, err := sshTargetFromFlags(flags, server.Id, server.Ip)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, inventoryHost{aliases[server.Id], server, target})
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Alias < hosts[j].Alias
+	})
+
+	return hosts, nil
+}
+
+// groupHosts maps each group to its sorted host names.
+func groupHosts(hosts []inventoryHost) map[string][]string {
+	groups := map[string][]string{}
+	for _, host := range hosts {
+		for _, group := range inventoryGroups(host.Server) {
+			groups[group] = append(groups[group], host.Alias)
+		}
+	}
+	return groups
+}
+
+func hostVars(host inventoryHost) map[string]interface{} {
+	vars := map[string]interface{}{
+		"ansible_host":         host.Target.Host,
+		"ansible_user":         host.Target.User,
+		"ansible_port":         host.Target.Port,
+		"tensordock_id":        host.Server.Id,
+		"tensordock_name":      host.Server.Name,
+		"tensordock_location":  host.Server.Location,
+		"tensordock_status":    host.Server.Status,
+		"tensordock_type":      host.Server.Type,
+		"tensordock_gpu_model": host.Server.GPUModel,
+		"tensordock_gpu_count": host.Server.GPUCount,
+		"tensordock_vcpus":     host.Server.VCPUs,
+		"tensordock_ram":       host.Server.Ram,
+		"tensordock_storage":   host.Server.Storage,
+		"tensordock_cost_hour": host.Server.Cost.HourOn,
+	}
+	if host.Target.Identity != "" {
+		vars["ansible_ssh_private_key_file"] = host.Target.Identity
+	}
+	return vars
+}
+
+func writeJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func ansibleInventory(hosts []inventoryHost, host string) error {
+	if host != "" {
+		for _, elem := range hosts {
+			if elem.Alias == host || elem.Server.Id == host {
+				return writeJSON(hostVars(elem))
+			}
+		}
+		return writeJSON(map[string]interface{}{})
+	}
+
+	groups := groupHosts(hosts)
+
+	inventory := map[string]interface{}{}
+	hostvars := map[string]interface{}{}
+	all := []string{}
+	for _, elem := range hosts {
+		hostvars[elem.Alias] = hostVars(elem)
+		all = append(all, elem.Alias)
+	}
+
+	for group, members := range groups {
+		inventory[group] = map[string]interface{}{"hosts": members}
+	}
+
+	inventory["all"] = map[string]interface{}{
+		"hosts":    all,
+		"children": sortedKeys(groups),
+	}
+	inventory["_meta"] = map[string]interface{}{"hostvars": hostvars}
+
+	return writeJSON(inventory)
+}
+
+func exportInventory(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+
+	list, err := flags.GetBool("list")
+	if err != nil {
+		return err
+	}
+
+	host, err := flags.GetString("host")
+	if err != nil {
+		return err
+	}
+
+	if (list || host != "") && format != "ansible" {
+		return errors.New("--list and --host are only supported with --format ansible")
+	}
+
+	if list && host != "" {
+		return errors.New("only one of --list or --host may be given")
+	}
+
+	hosts, err := inventoryHosts(flags)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "ansible":
+		return ansibleInventory(hosts, host)
+
+	case "hosts":
+		for _, elem := range hosts {
+			fmt.Printf("%v\t%v\t# %v\n", elem.Target.Host, elem.Alias, elem.Server.Id)
+		}
+
+	case "pdsh":
+		// genders format, usable with pdsh -F file -g group
+		for _, elem := range hosts {
+			fmt.Printf("%v\t%v\n", elem.Alias, strings.Join(inventoryGroups(elem.Server), ","))
+		}
+
+	case "clush":
+		// group file for clush's file-based group source
+		groups := groupHosts(hosts)
+		all := []string{}
+		for _, elem := range hosts {
+			all = append(all, elem.Alias)
+		}
+		groups["all"] = all
+
+		fmt.Println("tensordock:")
+		for _, group := range sortedKeys(groups) {
+			fmt.Printf("  %v: '%v'\n", group, strings.Join(groups[group], ","))
+		}
+
+	default:
+		return fmt.Errorf("invalid format %v, must be one of ansible, hosts, pdsh or clush", format)
+	}
+
+	return nil
+}
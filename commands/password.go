@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type storedPassword struct {
+	Name      string    `json:"name"`
+	AdminUser string    `json:"admin_user"`
+	AdminPass string    `json:"admin_pass"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const passwordAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func generatePassword() (string, error) {
+	password := make([]byte, 20)
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// resolveAdminPass picks the admin password from, in order, the positional
+// argument, --adminPassStdin, --adminPassFile or --generatePassword, and
+// falls back to a hidden prompt if allowed. It also reports whether the
+// password was generated.
+func resolveAdminPass(flags *pflag.FlagSet, positional string, prompt bool) (string, bool, error) {
+	fromStdin, err := flags.GetBool("adminPassStdin")
+	if err != nil {
+		return "", false, err
+	}
+
+	file, err := flags.GetString("adminPassFile")
+	if err != nil {
+		return "", false, err
+	}
+
+	generate, err := flags.GetBool("generatePassword")
+	if err != nil {
+		return "", false, err
+	}
+
+	sources := 0
+	for _, set := range []bool{positional != "", fromStdin, file != "", generate} {
+		if set {
+			sources++
+		}
+	}
+
+	if sources > 1 {
+		return "", false, errors.New("only one of admin_pass, --adminPassStdin, --adminPassFile or --generatePassword may be used")
+	}
+
+	switch {
+	case positional != "":
+		return positional, false, nil
+
+	case fromStdin:
+		bytes, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", false, err
+		}
+		return trimPassword(string(bytes))
+
+	case file != "":
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, err
+		}
+		return trimPassword(string(bytes))
+
+	case generate:
+		password, err := generatePassword()
+		return password, true, err
+
+	case prompt && isTerminal():
+		password, err := promptPassword("Admin password")
+		if err != nil {
+			return "", false, err
+		}
+
+		confirm, err := promptPassword("Confirm admin password")
+		if err != nil {
+			return "", false, err
+		}
+
+		if password != confirm {
+			return "", false, errors.New("passwords do not match")
+		}
+
+		return password, false, nil
+
+	case prompt:
+		return "", false, errors.New("no admin password given, use --adminPassStdin, --adminPassFile or --generatePassword")
+	}
+
+	return "", false, nil
+}
+
+func trimPassword(value string) (string, bool, error) {
+	password := strings.TrimRight(value, "\r\n")
+	if password == "" {
+		return "", false, errors.New("admin password is empty")
+	}
+	return password, false, nil
+}
+
+func loadPasswords() (map[string]storedPassword, error) {
+	passwords := map[string]storedPassword{}
+	if err := readState("passwords.json", &passwords); err != nil {
+		return nil, err
+	}
+	return passwords, nil
+}
+
+func savePassword(id string, name string, adminUser string, adminPass string) error {
+	passwords, err := loadPasswords()
+	if err != nil {
+		return err
+	}
+
+	passwords[id] = storedPassword{name, adminUser, adminPass, time.Now()}
+
+	return writeState("passwords.json", passwords)
+}
+
+func serverPassword(cmd *cobra.Command, args []string) error {
+	passwords, err := loadPasswords()
+	if err != nil {
+		return err
+	}
+
+	stored, ok := passwords[args[0]]
+	if !ok {
+		return fmt.Errorf("no password stored for server %v", args[0])
+	}
+
+	fmt.Println(stored.AdminPass)
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func isTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+func promptString(label string, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%v [%v]: ", label, def)
+	} else {
+		fmt.Printf("%v: ", label)
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if line = strings.TrimSpace(line); line == "" {
+		return def, nil
+	}
+
+	return line, nil
+}
+
+func promptInt(label string, def int, min int, max int) (int, error) {
+	for {
+		value, err := promptString(label, strconv.Itoa(def))
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := strconv.Atoi(value)
+		if err == nil && n >= min && (max <= 0 || n <= max) {
+			return n, nil
+		}
+
+		if max > 0 {
+			fmt.Printf("enter a number between %v and %v\n", min, max)
+		} else {
+			fmt.Printf("enter a number of at least %v\n", min)
+		}
+	}
+}
+
+// promptChoice shows a numbered list and returns the index of the chosen
+// option.
+func promptChoice(label string, options []string, def int) (int, error) {
+	if len(options) == 0 {
+		return 0, errors.New("nothing to choose from")
+	}
+
+	fmt.Println(label)
+	for i, option := range options {
+		fmt.Printf("  %v) %v\n", i+1, option)
+	}
+
+	n, err := promptInt("Choice", def+1, 1, len(options))
+	if err != nil {
+		return 0, err
+	}
+
+	return n - 1, nil
+}
+
+func promptConfirm(label string) (bool, error) {
+	value, err := promptString(label+" [y/N]", "")
+	if err != nil {
+		return false, err
+	}
+
+	value = strings.ToLower(value)
+	return value == "y" || value == "yes", nil
+}
+
+func promptPassword(label string) (string, error) {
+	fmt.Printf("%v: ", label)
+	bytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
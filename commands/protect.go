@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	protectCmd = &cobra.Command{
+		Use:   "protect [flags] server_id...",
+		Short: "Protect servers from being stopped or deleted",
+		Long: `Marks servers as protected so that "servers stop", "servers delete", the
+dashboard, autostop and schedules refuse to stop or delete them until
+"servers unprotect" is run.
+
+Protection is stored locally. Servers can also be protected for everyone
+sharing a config file by listing their IDs under "protectedServers".`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    protectServers,
+		PostRun: logAction("success"),
+	}
+	unprotectCmd = &cobra.Command{
+		Use:     "unprotect server_id...",
+		Short:   "Remove protection from servers",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    unprotectServers,
+		PostRun: logAction("success"),
+	}
+)
+
+type protection struct {
+	Name        string    `json:"name"`
+	Reason      string    `json:"reason,omitempty"`
+	ProtectedAt time.Time `json:"protected_at"`
+	Shared      bool      `json:"-"`
+}
+
+func init() {
+	protectCmd.Flags().String("reason", "", "Why the server is protected")
+	serversCmd.AddCommand(protectCmd)
+	serversCmd.AddCommand(unprotectCmd)
+}
+
+// loadProtections returns the locally protected servers merged with those
+// listed under "protectedServers" in the config file.
+func loadProtections() (map[string]protection, error) {
+	protections := map[string]protection{}
+	if err := readState("protected.json", &protections); err != nil {
+		return nil, err
+	}
+
+	for _, id := range viper.GetStringSlice("protectedServers") {
+		if _, ok := protections[id]; !ok {
+			protections[id] = protection{Reason: "listed in config file", Shared: true}
+		}
+	}
+
+	return protections, nil
+}
+
+func (p protection) String() string {
+	if p.Reason != "" {
+		return "yes (" + p.Reason + ")"
+	}
+	return "yes"
+}
+
+// checkProtected returns an error if the server may not be stopped or
+// deleted.
+func checkProtected(id string, action string) error {
+	protections, err := loadProtections()
+	if err != nil {
+		return err
+	}
+
+	p, ok := protections[id]
+	if !ok {
+		return nil
+	}
+
+	msg := fmt.Sprintf("server %v is protected", id)
+	if p.Reason != "" {
+		msg += " (" + p.Reason + ")"
+	}
+
+	if p.Shared {
+		return fmt.Errorf("%v, remove it from protectedServers in %v to %v it", msg, viper.ConfigFileUsed(), action)
+	}
+
+	return fmt.Errorf("%v, run \"tensordock-cli servers unprotect %v\" to %v it", msg, id, action)
+}
+
+// saveProtections writes the locally protected servers, or only reports
+// what would be saved under --dryRun.
+func saveProtections(protections map[string]protection) error {
+	if client.DryRun {
+		log.Printf("would save %v protected server(s)", len(protections))
+		return nil
+	}
+
+	return writeState("protected.json", protections)
+}
+
+func protectServers(cmd *cobra.Command, args []string) error {
+	reason, err := cmd.Flags().GetString("reason")
+	if err != nil {
+		return err
+	}
+
+	protections := map[string]protection{}
+	if err := readState("protected.json", &protections); err != nil {
+		return err
+	}
+
+	for _, id := range args {
+		res, err := client.GetServer(id)
+		if err != nil {
+			return err
+		}
+
+		if !res.Success {
+			return fmt.Errorf("%v: %v", id, res.Error)
+		}
+
+		protections[id] = protection{Name: res.Server.Name, Reason: reason, ProtectedAt: time.Now()}
+	}
+
+	return saveProtections(protections)
+}
+
+func unprotectServers(cmd *cobra.Command, args []string) error {
+	protections := map[string]protection{}
+	if err := readState("protected.json", &protections); err != nil {
+		return err
+	}
+
+	shared := map[string]bool{}
+	for _, id := range viper.GetStringSlice("protectedServers") {
+		shared[id] = true
+	}
+
+	problems := []string{}
+	for _, id := range args {
+		_, local := protections[id]
+		switch {
+		case shared[id]:
+			problems = append(problems, fmt.Sprintf("%v is listed under protectedServers in %v", id, viper.ConfigFileUsed()))
+		case !local:
+			problems = append(problems, fmt.Sprintf("%v is not protected", id))
+		}
+		delete(protections, id)
+	}
+
+	if err := saveProtections(protections); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	provisionCmd = &cobra.Command{
+		Use:   "provision [flags] server_id",
+		Short: "Run bootstrap scripts on a server",
+		Long: `Waits for the server to be running and reachable over SSH, then uploads and
+runs the bootstrap scripts in order as the admin user, streaming their output.
+Stops at the first failing script. Results are recorded locally and can be
+shown with --status.
+
+Authentication uses the SSH agent, the default keys in ~/.ssh and the admin
+password stored with "servers deploy --storePassword".`,
+		Args: cobra.ExactArgs(1),
+		RunE: provisionServer,
+	}
+)
+
+const runScriptCommand = `f=$(mktemp) && cat > "$f" && chmod +x "$f" && "$f" </dev/null; rc=$?; rm -f "$f"; exit $rc`
+
+type provisionRecord struct {
+	Script     string    `json:"script"`
+	Success    bool      `json:"success"`
+	ExitStatus int       `json:"exit_status"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	Duration   string    `json:"duration"`
+}
+
+func init() {
+	provisionCmd.Flags().StringSlice("bootstrap", nil, "Script to run (repeatable)")
+	provisionCmd.Flags().String("bootstrapDir", "", "Directory of scripts to run in lexical order")
+	provisionCmd.Flags().String("user", "", "User to log in as (defaults to the stored admin user)")
+	provisionCmd.Flags().Duration("sshTimeout", 15*time.Minute, "Maximum time to wait for the server to accept SSH connections")
+	provisionCmd.Flags().Int("port", 22, "SSH port (config: ssh.port)")
+	provisionCmd.Flags().String("identity", "", "Private key file to authenticate with (config: ssh.identity)")
+	provisionCmd.Flags().Bool("status", false, "Show recorded provisioning results instead of running scripts")
+	serversCmd.AddCommand(provisionCmd)
+}
+
+// bootstrapScripts collects the scripts given with --bootstrap followed by
+// the files in --bootstrapDir sorted by name.
+func bootstrapScripts(flags *pflag.FlagSet) ([]string, error) {
+	scripts, err := flags.GetStringSlice("bootstrap")
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := flags.GetString("bootstrapDir")
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		names := []string{}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			scripts = append(scripts, filepath.Join(dir, name))
+		}
+	}
+
+	for _, script := range scripts {
+		if _, err := os.Stat(script); err != nil {
+			return nil, err
+		}
+	}
+
+	return scripts, nil
+}
+
+func loadProvisionRecords() (map[string][]provisionRecord, error) {
+	records := map[string][]provisionRecord{}
+	if err := readState("provision.json", &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func saveProvisionRecord(id string, record provisionRecord) error {
+	records, err := loadProvisionRecords()
+	if err != nil {
+		return err
+	}
+
+	records[id] = append(records[id], record)
+
+	return writeState("provision.json", records)
+}
+
+// runBootstrap waits for the server and runs the scripts one by one,
+// stopping at the first failure.
+func runBootstrap(flags *pflag.FlagSet, id string, user string, password string, scripts []string, timeout time.Duration) error {
+	log.Printf("waiting for %v to become reachable", id)
+
+	target, err := waitForSSH(flags, id, timeout)
+	if err != nil {
+		return err
+	}
+
+	target.User = user
+	target.Password = password
+
+	conn, err := target.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, script := range scripts {
+		record := provisionRecord{Script: script, StartedAt: time.Now()}
+
+		log.Printf("running %v", script)
+		err := runScript(conn, script)
+		record.Duration = time.Since(record.StartedAt).Truncate(time.Second).String()
+
+		if err != nil {
+			record.Error = err.Error()
+			record.ExitStatus = -1
+
+			var exitErr *ssh.ExitError
+			if errors.As(err, &exitErr) {
+				record.ExitStatus = exitErr.ExitStatus()
+			}
+		} else {
+			record.Success = true
+		}
+
+		if err := saveProvisionRecord(id, record); err != nil {
+			log.Printf("warning: failed to record result: %v", err)
+		}
+
+		if !record.Success {
+			return fmt.Errorf("%v failed: %v", script, err)
+		}
+	}
+
+	return nil
+}
+
+func runScript(conn *ssh.Client, script string) error {
+	file, err := os.Open(script)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = file
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	return session.Run(runScriptCommand)
+}
+
+func provisionServer(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	id := args[0]
+
+	status, err := flags.GetBool("status")
+	if err != nil {
+		return err
+	}
+
+	if status {
+		return provisionStatus(id)
+	}
+
+	scripts, err := bootstrapScripts(flags)
+	if err != nil {
+		return err
+	}
+
+	if len(scripts) == 0 {
+		return errors.New("no scripts given, use --bootstrap or --bootstrapDir")
+	}
+
+	user, err := flags.GetString("user")
+	if err != nil {
+		return err
+	}
+
+	timeout, err := flags.GetDuration("sshTimeout")
+	if err != nil {
+		return err
+	}
+
+	passwords, err := loadPasswords()
+	if err != nil {
+		return err
+	}
+
+	stored := passwords[id]
+	if user == "" {
+		user = stored.AdminUser
+	}
+	if user == "" {
+		user = "user"
+	}
+
+	if err := runBootstrap(flags, id, user, stored.AdminPass, scripts, timeout); err != nil {
+		return err
+	}
+
+	log.Println("success")
+
+	return nil
+}
+
+func provisionStatus(id string) error {
+	records, err := loadProvisionRecords()
+	if err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Started", "Script", "Result", "Exit Status", "Duration"})
+	for _, elem := range records[id] {
+		result := "success"
+		if !elem.Success {
+			result = "failed"
+		}
+		t.AppendRow(table.Row{elem.StartedAt.Format(time.RFC3339), elem.Script, result, elem.ExitStatus, elem.Duration})
+	}
+	t.Render()
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -15,17 +17,47 @@ var (
 	client  *api.Client
 
 	rootCmd = &cobra.Command{
-		Use:          "tensordock-cli",
-		Short:        "A brief description of your application",
-		SilenceUsage: true,
+		Use:           "tensordock-cli",
+		Short:         "A brief description of your application",
+		SilenceUsage:  true,
+		SilenceErrors: true,
 	}
 )
 
-func Execute() {
+// exitCodeError makes the CLI exit with a specific status, such as the one
+// of a command run over SSH, without printing an error.
+type exitCodeError struct {
+	code int
+}
+
+func (err *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %v", err.code)
+}
+
+// Execute runs the CLI and prints the error, if any, unless it only
+// carries an exit status.
+func Execute() error {
 	err := rootCmd.Execute()
+
+	var exitErr *exitCodeError
+	if err != nil && !errors.As(err, &exitErr) {
+		rootCmd.PrintErrln("Error:", err)
+	}
+
+	return err
+}
+
+// ExitCode returns the status the CLI should exit with after Execute
+// returned err.
+func ExitCode(err error) int {
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
 	if err != nil {
-		os.Exit(1)
+		return 1
 	}
+	return 0
 }
 
 func init() {
@@ -36,6 +68,7 @@ func init() {
 	pflags.String("apiKey", "", "API key")
 	pflags.String("apiToken", "", "API token")
 	pflags.Bool("debug", false, "Enable debug mode")
+	pflags.Bool("dryRun", false, "Print the API requests that would change servers instead of sending them, and only report what autostop, schedule run, sync and local state changes would do")
 
 	viper.BindPFlag("apiKey", pflags.Lookup("apiKey"))
 	viper.BindPFlag("apiToken", pflags.Lookup("apiToken"))
@@ -67,4 +100,6 @@ func initConfig() {
 	debug := viper.GetBool("debug")
 
 	client = api.NewClient(serviceUrl, apiKey, apiToken, debug)
+	client.DryRun, _ = rootCmd.PersistentFlags().GetBool("dryRun")
+	client.OnMutation = recordMutation
 }
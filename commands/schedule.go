@@ -0,0 +1,419 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	scheduleCmd = &cobra.Command{
+		Use:   "schedule",
+		Short: "Manage start/stop schedules",
+	}
+	listScheduleCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List schedules",
+		RunE:  listSchedules,
+	}
+	addScheduleCmd = &cobra.Command{
+		Use:     "add [flags] name",
+		Short:   "Add or replace a schedule",
+		Args:    cobra.ExactArgs(1),
+		RunE:    addSchedule,
+		PostRun: logAction("schedule saved"),
+	}
+	removeScheduleCmd = &cobra.Command{
+		Use:     "remove name",
+		Short:   "Remove a schedule",
+		Args:    cobra.ExactArgs(1),
+		RunE:    removeSchedule,
+		PostRun: logAction("schedule removed"),
+	}
+	runScheduleCmd = &cobra.Command{
+		Use:   "run",
+		Short: "Run the scheduler daemon",
+		RunE:  runSchedules,
+	}
+)
+
+type schedule struct {
+	Name     string   `mapstructure:"name"`
+	Start    string   `mapstructure:"start"`
+	Stop     string   `mapstructure:"stop"`
+	Timezone string   `mapstructure:"timezone"`
+	Servers  []string `mapstructure:"servers"`
+	Selector string   `mapstructure:"selector"`
+}
+
+type scheduleState struct {
+	LastRun time.Time `json:"last_run"`
+}
+
+type scheduleAction struct {
+	Action string
+	At     time.Time
+}
+
+// maxCatchUp limits how far back --catchUp last looks for missed events.
+const maxCatchUp = 7 * 24 * time.Hour
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+func init() {
+	scheduleCmd.AddCommand(listScheduleCmd)
+
+	scheduleCmd.AddCommand(addScheduleCmd)
+	addScheduleCmd.Flags().String("start", "", "Cron expression for starting servers (e.g. \"0 8 * * 1-5\")")
+	addScheduleCmd.Flags().String("stop", "", "Cron expression for stopping servers (e.g. \"0 20 * * 1-5\")")
+	addScheduleCmd.Flags().String("timezone", "Local", "Time zone the cron expressions are evaluated in")
+	addScheduleCmd.Flags().StringSlice("server", nil, "Server ID to apply the schedule to (repeatable)")
+	addScheduleCmd.Flags().String("selector", "", "Apply the schedule to servers matching key=value pairs (e.g. gpu=A4000,location=na-us-*)")
+
+	scheduleCmd.AddCommand(removeScheduleCmd)
+
+	scheduleCmd.AddCommand(runScheduleCmd)
+	runScheduleCmd.Flags().String("catchUp", "skip", "What to do with events missed while the daemon was down (skip or last)")
+
+	rootCmd.AddCommand(scheduleCmd)
+}
+
+func loadSchedules() ([]schedule, error) {
+	var schedules []schedule
+	if err := viper.UnmarshalKey("schedules", &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// saveSchedules writes the schedules to the config file. Nothing is written
+// with --dryRun.
+func saveSchedules(schedules []schedule) error {
+	if client.DryRun {
+		log.Printf("would save %v schedule(s) to %v", len(schedules), viper.ConfigFileUsed())
+		return nil
+	}
+
+	viper.Set("schedules", schedules)
+	return viper.WriteConfig()
+}
+
+func (s schedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+func (s schedule) targets() string {
+	targets := append([]string{}, s.Servers...)
+	if s.Selector != "" {
+		targets = append(targets, s.Selector)
+	}
+	return strings.Join(targets, ", ")
+}
+
+// due returns the latest action that falls in (from, to], if any.
+func (s schedule) due(from time.Time, to time.Time) (*scheduleAction, error) {
+	loc, err := s.location()
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *scheduleAction
+	for action, expr := range map[string]string{"start": s.Start, "stop": s.Stop} {
+		if expr == "" {
+			continue
+		}
+
+		sched, err := cronParser.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		var last time.Time
+		for next := sched.Next(from.In(loc)); !next.After(to); next = sched.Next(next) {
+			last = next
+		}
+
+		if !last.IsZero() && (latest == nil || last.After(latest.At)) {
+			latest = &scheduleAction{action, last}
+		}
+	}
+
+	return latest, nil
+}
+
+func listSchedules(cmd *cobra.Command, args []string) error {
+	schedules, err := loadSchedules()
+	if err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Name", "Start", "Stop", "Timezone", "Targets"})
+	for _, elem := range schedules {
+		t.AppendRow(table.Row{elem.Name, elem.Start, elem.Stop, elem.Timezone, elem.targets()})
+	}
+	t.Render()
+
+	return nil
+}
+
+func addSchedule(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	start, err := flags.GetString("start")
+	if err != nil {
+		return err
+	}
+
+	stop, err := flags.GetString("stop")
+	if err != nil {
+		return err
+	}
+
+	timezone, err := flags.GetString("timezone")
+	if err != nil {
+		return err
+	}
+
+	servers, err := flags.GetStringSlice("server")
+	if err != nil {
+		return err
+	}
+
+	sel, err := flags.GetString("selector")
+	if err != nil {
+		return err
+	}
+
+	if start == "" && stop == "" {
+		return errors.New("at least one of --start or --stop must be set")
+	}
+
+	if len(servers) == 0 && sel == "" {
+		return errors.New("at least one of --server or --selector must be set")
+	}
+
+	for _, expr := range []string{start, stop} {
+		if expr == "" {
+			continue
+		}
+		if _, err := cronParser.Parse(expr); err != nil {
+			return fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+	}
+
+	if _, err := parseSelector(sel); err != nil {
+		return err
+	}
+
+	s := schedule{
+		Name:     args[0],
+		Start:    start,
+		Stop:     stop,
+		Timezone: timezone,
+		Servers:  servers,
+		Selector: sel,
+	}
+
+	if _, err := s.location(); err != nil {
+		return err
+	}
+
+	schedules, err := loadSchedules()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, elem := range schedules {
+		if elem.Name == s.Name {
+			schedules[i] = s
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		schedules = append(schedules, s)
+	}
+
+	return saveSchedules(schedules)
+}
+
+func removeSchedule(cmd *cobra.Command, args []string) error {
+	schedules, err := loadSchedules()
+	if err != nil {
+		return err
+	}
+
+	kept := []schedule{}
+	for _, elem := range schedules {
+		if elem.Name != args[0] {
+			kept = append(kept, elem)
+		}
+	}
+
+	if len(kept) == len(schedules) {
+		return fmt.Errorf("schedule %v not found", args[0])
+	}
+
+	return saveSchedules(kept)
+}
+
+func runSchedules(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	catchUp, err := flags.GetString("catchUp")
+	if err != nil {
+		return err
+	}
+
+	schedules, err := loadSchedules()
+	if err != nil {
+		return err
+	}
+
+	if len(schedules) == 0 {
+		return errors.New("no schedules defined")
+	}
+
+	var state scheduleState
+	if err := readState("schedule.json", &state); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if state.LastRun, err = catchUpFrom(catchUp, state.LastRun, now); err != nil {
+		return err
+	}
+
+	if catchUp == "last" {
+		log.Printf("catching up on events since %v", state.LastRun.Format(time.RFC3339))
+	}
+
+	for {
+		now = time.Now()
+		for _, s := range schedules {
+			action, err := s.due(state.LastRun, now)
+			if err != nil {
+				log.Printf("warning: schedule %v: %v", s.Name, err)
+				continue
+			}
+
+			if action != nil {
+				applySchedule(s, action.Action)
+			}
+		}
+
+		state.LastRun = now
+		if err := writeState("schedule.json", state); err != nil {
+			log.Printf("warning: failed to save scheduler state: %v", err)
+		}
+
+		time.Sleep(time.Until(now.Truncate(time.Minute).Add(time.Minute)))
+	}
+}
+
+// catchUpFrom returns the time to look for due events from when the daemon
+// starts. "skip" ignores everything missed while it was down, "last" goes
+// back to the previous run, but no further than a week.
+func catchUpFrom(policy string, lastRun time.Time, now time.Time) (time.Time, error) {
+	switch policy {
+	case "skip":
+		return now, nil
+	case "last":
+		if lastRun.IsZero() || now.Sub(lastRun) > maxCatchUp {
+			return now.Add(-maxCatchUp), nil
+		}
+		return lastRun, nil
+	}
+
+	return time.Time{}, errors.New("unknown catch-up policy")
+}
+
+func applySchedule(s schedule, action string) {
+	servers, err := listServers()
+	if err != nil {
+		log.Printf("warning: schedule %v: %v", s.Name, err)
+		return
+	}
+
+	sel, err := parseSelector(s.Selector)
+	if err != nil {
+		log.Printf("warning: schedule %v: %v", s.Name, err)
+		return
+	}
+
+	ids := map[string]bool{}
+	for _, id := range s.Servers {
+		ids[id] = true
+	}
+
+	if s.Selector != "" {
+		for id, server := range servers {
+			if sel.matches(server) {
+				ids[id] = true
+			}
+		}
+	}
+
+	sorted := []string{}
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	for _, id := range sorted {
+		server, ok := servers[id]
+		if !ok {
+			log.Printf("warning: schedule %v: server %v not found", s.Name, id)
+			continue
+		}
+
+		if (action == "start") == isRunning(server) {
+			continue
+		}
+
+		if action == "stop" {
+			if err := checkProtected(id, action); err != nil {
+				log.Printf("warning: schedule %v: %v", s.Name, err)
+				continue
+			}
+		}
+
+		if client.DryRun {
+			log.Printf("schedule %v: would %v %v (%v)", s.Name, action, id, server.Name)
+			continue
+		}
+
+		call := client.StopServer
+		if action == "start" {
+			call = client.StartServer
+		}
+
+		res, err := call(id)
+		if err != nil {
+			log.Printf("warning: schedule %v: failed to %v %v: %v", s.Name, action, id, err)
+			continue
+		}
+
+		if !res.Success {
+			log.Printf("warning: schedule %v: failed to %v %v: %v", s.Name, action, id, res.Error)
+			continue
+		}
+
+		log.Printf("schedule %v: %v %v (%v)", s.Name, action, id, server.Name)
+	}
+}
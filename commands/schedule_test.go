@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleDue(t *testing.T) {
+	weekdays := schedule{Start: "0 8 * * 1-5", Stop: "0 20 * * 1-5", Timezone: "UTC"}
+	newYork := schedule{Start: "0 8 * * *", Timezone: "America/New_York"}
+
+	// 2022-06-15 is a Wednesday
+	at := func(day int, hour int, minute int, second int) time.Time {
+		return time.Date(2022, 6, day, hour, minute, second, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		schedule schedule
+		from     time.Time
+		to       time.Time
+		action   string
+		at       time.Time
+		wantErr  bool
+	}{
+		{"start on the minute", weekdays, at(15, 7, 59, 0), at(15, 8, 0, 0), "start", at(15, 8, 0, 0), false},
+		{"start within the minute", weekdays, at(15, 7, 59, 30), at(15, 8, 0, 30), "start", at(15, 8, 0, 0), false},
+		{"from is exclusive", weekdays, at(15, 8, 0, 0), at(15, 8, 1, 0), "", time.Time{}, false},
+		{"just before start", weekdays, at(15, 7, 0, 0), at(15, 7, 59, 59), "", time.Time{}, false},
+		{"latest of start and stop", weekdays, at(15, 7, 0, 0), at(15, 21, 0, 0), "stop", at(15, 20, 0, 0), false},
+		{"weekend", weekdays, at(18, 7, 0, 0), at(18, 21, 0, 0), "", time.Time{}, false},
+		{"catch up over a weekend", weekdays, at(17, 19, 0, 0), at(20, 8, 30, 0), "start", at(20, 8, 0, 0), false},
+		{"catch up over a week", weekdays, at(8, 21, 0, 0), at(15, 21, 0, 0), "stop", at(15, 20, 0, 0), false},
+		{"time zone", newYork, at(15, 11, 59, 0), at(15, 12, 0, 0), "start", at(15, 12, 0, 0), false},
+		{"time zone before start", newYork, at(15, 7, 59, 0), at(15, 8, 0, 0), "", time.Time{}, false},
+		{"invalid cron expression", schedule{Start: "0 8 * *"}, at(15, 7, 0, 0), at(15, 9, 0, 0), "", time.Time{}, true},
+		{"invalid time zone", schedule{Start: "0 8 * * *", Timezone: "Mars/Olympus"}, at(15, 7, 0, 0), at(15, 9, 0, 0), "", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := test.schedule.due(test.from, test.to)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: error = %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+
+		if test.action == "" {
+			if got != nil {
+				t.Errorf("%v: got %v at %v, want nothing due", test.name, got.Action, got.At)
+			}
+			continue
+		}
+
+		if got == nil {
+			t.Errorf("%v: got nothing due, want %v at %v", test.name, test.action, test.at)
+			continue
+		}
+
+		if got.Action != test.action || !got.At.Equal(test.at) {
+			t.Errorf("%v: got %v at %v, want %v at %v", test.name, got.Action, got.At, test.action, test.at)
+		}
+	}
+}
+
+func TestCatchUpFrom(t *testing.T) {
+	now := time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		policy  string
+		lastRun time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{"skip", "skip", now.Add(-time.Hour), now, false},
+		{"skip on first run", "skip", time.Time{}, now, false},
+		{"last", "last", now.Add(-time.Hour), now.Add(-time.Hour), false},
+		{"last on first run", "last", time.Time{}, now.Add(-maxCatchUp), false},
+		{"last within a week", "last", now.Add(-maxCatchUp + time.Minute), now.Add(-maxCatchUp + time.Minute), false},
+		{"last capped at a week", "last", now.Add(-30 * 24 * time.Hour), now.Add(-maxCatchUp), false},
+		{"unknown policy", "all", now.Add(-time.Hour), time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := catchUpFrom(test.policy, test.lastRun, now)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: error = %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
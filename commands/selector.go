@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+)
+
+// selector matches servers against comma-separated key=value pairs. Values
+// may contain shell-style wildcards (e.g. "location=na-us-*,gpu=A4000").
+type selector map[string]string
+
+var selectorFields = map[string]func(api.Server) string{
+	"id":       func(s api.Server) string { return s.Id },
+	"name":     func(s api.Server) string { return s.Name },
+	"location": func(s api.Server) string { return s.Location },
+	"status":   func(s api.Server) string { return s.Status },
+	"type":     func(s api.Server) string { return s.Type },
+	"gpu":      func(s api.Server) string { return s.GPUModel },
+	"cpu":      func(s api.Server) string { return s.CPUModel },
+	"ip":       func(s api.Server) string { return s.Ip },
+}
+
+func parseSelector(value string) (selector, error) {
+	sel := selector{}
+	if strings.TrimSpace(value) == "" {
+		return sel, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid selector %q, expected key=value", pair)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		if _, ok := selectorFields[key]; !ok {
+			return nil, fmt.Errorf("unknown selector key %q", key)
+		}
+
+		if _, err := path.Match(parts[1], ""); err != nil {
+			return nil, fmt.Errorf("invalid selector pattern %q", parts[1])
+		}
+
+		sel[key] = strings.TrimSpace(parts[1])
+	}
+
+	return sel, nil
+}
+
+func (sel selector) matches(server api.Server) bool {
+	for key, pattern := range sel {
+		value := selectorFields[key](server)
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(value)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// listServers returns every server on the account keyed by id, filling in
+// ids missing from the response body.
+func listServers() (map[string]api.Server, error) {
+	res, err := client.ListServers()
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.Success {
+		return nil, errors.New(res.Error)
+	}
+
+	servers := map[string]api.Server{}
+	for id, server := range res.Servers {
+		if server.Id == "" {
+			server.Id = id
+		}
+		servers[server.Id] = server
+	}
+
+	return servers, nil
+}
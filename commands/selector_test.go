@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    selector
+		wantErr bool
+	}{
+		{"", selector{}, false},
+		{"  ", selector{}, false},
+		{"name=train-*", selector{"name": "train-*"}, false},
+		{"location=na-us-*, gpu = A4000", selector{"location": "na-us-*", "gpu": "A4000"}, false},
+		{"status=Running,type=gpu,ip=10.0.0.?", selector{"status": "Running", "type": "gpu", "ip": "10.0.0.?"}, false},
+		{"name=a=b", selector{"name": "a=b"}, false},
+		{"name", nil, true},
+		{"name=train,", nil, true},
+		{"owner=alice", nil, true},
+		{"name=[", nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseSelector(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseSelector(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseSelector(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	server := api.Server{Id: "s1", Name: "train-01", Location: "na-us-chi-1", Status: "Running", Type: "gpu", GPUModel: "A4000", Ip: "10.0.0.5"}
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", true},
+		{"name=train-*", true},
+		{"name=TRAIN-01", true},
+		{"name=train", false},
+		{"location=na-us-*,status=running", true},
+		{"location=na-us-*,status=stopped", false},
+		{"gpu=A4000,ip=10.0.0.?", true},
+		{"cpu=*", true},
+		{"cpu=?*", false},
+	}
+
+	for _, test := range tests {
+		sel, err := parseSelector(test.value)
+		if err != nil {
+			t.Errorf("parseSelector(%q): %v", test.value, err)
+			continue
+		}
+		if got := sel.matches(server); got != test.want {
+			t.Errorf("selector %q matches = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
@@ -7,11 +7,16 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/caguiclajmg/tensordock-cli/api"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
 )
 
 var (
@@ -52,9 +57,13 @@ var (
 		PostRun: logAction("success"),
 	}
 	deployCmd = &cobra.Command{
-		Use:     "deploy [flags] name admin_user admin_pass",
-		Short:   "Deploy a server",
-		Args:    cobra.ExactArgs(3),
+		Use:   "deploy [flags] name admin_user [admin_pass]",
+		Short: "Deploy a server",
+		Long: `Deploy a server. Run without arguments on a terminal (or with --interactive) to be guided through the options.
+
+If admin_pass is omitted, the password is read from --adminPassStdin or
+--adminPassFile, generated with --generatePassword or prompted for.`,
+		Args:    deployArgs,
 		RunE:    deployServer,
 		PostRun: logAction("success"),
 	}
@@ -65,10 +74,16 @@ var (
 		RunE:  manageServer,
 	}
 	sshCmd = &cobra.Command{
-		Use:   "ssh server_id",
+		Use:   "ssh [flags] server_id [-- command...]",
 		Short: "Launch an SSH sesion with a server",
-		Args:  cobra.ExactArgs(1),
-		RunE:  sshServer,
+		Long: `Launch an SSH session with a server, or run a command on it if one is given
+after "--".
+
+Host keys are remembered per server ID on first connection and any change is
+refused. Use --native to connect with the built-in client, which falls back to
+the external one if it cannot log in.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: sshServer,
 	}
 	restartCmd = &cobra.Command{
 		Use:     "restart [flags] server_id",
@@ -84,6 +99,12 @@ var (
 		RunE:    modifyServer,
 		PostRun: logAction("success"),
 	}
+	passwordCmd = &cobra.Command{
+		Use:   "password server_id",
+		Short: "Show the stored admin password of a server",
+		Args:  cobra.ExactArgs(1),
+		RunE:  serverPassword,
+	}
 	statusCmd = &cobra.Command{
 		Use:   "status server_id",
 		Short: "Get server status",
@@ -94,18 +115,21 @@ var (
 
 func init() {
 	serversCmd.AddCommand(listCmd)
+	addWatchFlags(listCmd.Flags())
 
 	serversCmd.AddCommand(infoCmd)
 
 	serversCmd.AddCommand(stopCmd)
+	addConfirmFlags(stopCmd.Flags())
 
 	serversCmd.AddCommand(startCmd)
 
 	serversCmd.AddCommand(deleteCmd)
+	addConfirmFlags(deleteCmd.Flags())
 
 	serversCmd.AddCommand(deployCmd)
-	deployCmd.Flags().String("gpuModel", "Quadro_4000", "The GPU model that you would like to provision")
-	deployCmd.Flags().String("location", "na-us-chi-1", "Location")
+	deployCmd.Flags().String("gpuModel", "Quadro_4000", "The GPU model that you would like to provision (\"any-of:A4000,A5000\" to pick the cheapest in stock)")
+	deployCmd.Flags().String("location", "na-us-chi-1", "Location (\"auto\" to pick the cheapest in-stock location)")
 	deployCmd.Flags().String("instanceType", "gpu", "Either \"gpu\" or \"cpu\"")
 	deployCmd.Flags().Int("gpuCount", 1, "The number of GPUs of the model you specified earlier")
 	deployCmd.Flags().String("cpuModel", "Intel_Xeon_v4", "The CPU model that you would like to provision")
@@ -114,49 +138,99 @@ func init() {
 	deployCmd.Flags().String("storageClass", "io1", "io1 or st1, depending on storage class desired")
 	deployCmd.Flags().Int("ram", 4, "Number of GB of RAM to be deployed.")
 	deployCmd.Flags().String("os", "Ubuntu 20.04 LTS", "Operating system")
+	deployCmd.Flags().Bool("whenAvailable", false, "Wait until stock is available before deploying; --location, --gpuModel and --cpuModel accept comma-separated fallbacks in order of preference")
+	deployCmd.Flags().Duration("timeout", 0, "Maximum time to wait with --whenAvailable (0 to wait forever)")
+	deployCmd.Flags().Duration("interval", time.Minute, "Time between stock polls with --whenAvailable")
+	deployCmd.Flags().Int("minVram", 0, "Minimum GPU VRAM in GB when choosing a GPU model automatically")
+	addProvisionFlags(deployCmd.Flags())
+	addConfirmFlags(deployCmd.Flags())
+	deployCmd.Flags().Bool("interactive", false, "Choose the server options interactively")
+	deployCmd.Flags().Bool("skipValidation", false, "Send the request without client-side validation")
+	deployCmd.Flags().String("regionPrefix", "", "Only consider locations starting with this prefix with --location auto (e.g. na-us)")
 
 	serversCmd.AddCommand(manageCmd)
 
 	serversCmd.AddCommand(sshCmd)
 	sshCmd.Flags().String("bin", "ssh", "Name of SSH client executable (e.g. ssh, mosh)")
-	sshCmd.Flags().String("user", "user", "User account to use for login")
-	sshCmd.Flags().String("extraFlags", "", "Extra flags to pass to the SSH client")
+	sshCmd.Flags().String("extraFlags", "", "Extra flags to pass to the SSH client, split like a shell would")
+	sshCmd.Flags().Bool("native", false, "Use the built-in SSH client (config: ssh.native)")
+	sshCmd.Flags().Bool("resetHostKey", false, "Forget the remembered host key of the server before connecting")
+	addSSHFlags(sshCmd.Flags())
 
 	serversCmd.AddCommand(restartCmd)
+	addConfirmFlags(restartCmd.Flags())
 
 	serversCmd.AddCommand(modifyCmd)
-	modifyCmd.Flags().String("instanceType", "gpu", "Either \"gpu\" or \"cpu\"")
-	modifyCmd.Flags().String("gpuModel", "Quadro_4000", "The GPU model that you would like to provision")
-	modifyCmd.Flags().Int("gpuCount", 1, "The number of GPUs of the model you specified earlier")
-	modifyCmd.Flags().String("cpuModel", "Intel_Xeon_v4", "The CPU model that you would like to provision")
-	modifyCmd.Flags().Int("vcpus", 2, "Number of vCPUs that you would like")
-	modifyCmd.Flags().Int("storage", 20, "Number of GB of networked storage")
-	modifyCmd.Flags().Int("ram", 4, "Number of GB of RAM to be deployed.")
+	modifyCmd.Flags().String("instanceType", "", "Either \"gpu\" or \"cpu\" (default: unchanged)")
+	modifyCmd.Flags().String("gpuModel", "", "The GPU model that you would like to provision (default: unchanged)")
+	modifyCmd.Flags().Int("gpuCount", 0, "The number of GPUs of the model you specified earlier (default: unchanged)")
+	modifyCmd.Flags().String("cpuModel", "", "The CPU model that you would like to provision (default: unchanged)")
+	modifyCmd.Flags().Int("vcpus", 0, "Number of vCPUs that you would like (default: unchanged)")
+	modifyCmd.Flags().Int("storage", 0, "Number of GB of networked storage (default: unchanged)")
+	modifyCmd.Flags().Int("ram", 0, "Number of GB of RAM (default: unchanged)")
+	modifyCmd.Flags().Bool("skipValidation", false, "Send the request without client-side validation")
+	addConfirmFlags(modifyCmd.Flags())
 
 	serversCmd.AddCommand(statusCmd)
+	addWatchFlags(statusCmd.Flags())
+
+	serversCmd.AddCommand(passwordCmd)
 
 	rootCmd.AddCommand(serversCmd)
 }
 
 func serverList(cmd *cobra.Command, args []string) error {
-	res, err := client.ListServers()
-	if err != nil {
-		return err
-	}
-
-	if !res.Success {
-		return errors.New(res.Error)
-	}
-
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"Id", "Name", "Location", "Status"})
-	for _, elem := range res.Servers {
-		t.AppendRow(table.Row{elem.Id, elem.Name, elem.Location, elem.Status})
-	}
-	t.Render()
-
-	return nil
+	return watchSpec[api.Server]{
+		fetch: func() ([]api.Server, error) {
+			servers, err := listServers()
+			if err != nil {
+				return nil, err
+			}
+
+			sorted := []api.Server{}
+			for _, id := range sortedKeys(servers) {
+				sorted = append(sorted, servers[id])
+			}
+			return sorted, nil
+		},
+		key: func(server api.Server) string {
+			return server.Id
+		},
+		render: func(servers []api.Server) error {
+			protections, err := loadProtections()
+			if err != nil {
+				return err
+			}
+
+			t := table.NewWriter()
+			t.SetOutputMirror(os.Stdout)
+			t.AppendHeader(table.Row{"Id", "Name", "Location", "Status", "Protected"})
+			for _, elem := range servers {
+				protected := ""
+				if _, ok := protections[elem.Id]; ok {
+					protected = "yes"
+				}
+				t.AppendRow(table.Row{elem.Id, elem.Name, elem.Location, elem.Status, protected})
+			}
+			t.Render()
+			return nil
+		},
+		// cost figures change on every poll, so only the fields shown by
+		// the other commands count as a change
+		equal: func(before api.Server, after api.Server) bool {
+			return before.Status == after.Status &&
+				before.Ip == after.Ip &&
+				before.Name == after.Name &&
+				before.StorageClass == after.StorageClass &&
+				currentModifySpec(before) == currentModifySpec(after)
+		},
+		change: func(before api.Server, after api.Server) (string, interface{}) {
+			if before.Status != after.Status {
+				return "status_changed", nil
+			}
+			return "changed", nil
+		},
+	}.run(cmd.Flags())
 }
 
 func serverInfo(cmd *cobra.Command, args []string) error {
@@ -191,6 +265,17 @@ func serverInfo(cmd *cobra.Command, args []string) error {
 		{"name": "vCPUs", "value": strconv.Itoa(res.Server.VCPUs)},
 	}
 
+	protections, err := loadProtections()
+	if err != nil {
+		return err
+	}
+
+	protected := "no"
+	if p, ok := protections[server]; ok {
+		protected = p.String()
+	}
+	props = append(props, map[string]string{"name": "Protected", "value": protected})
+
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
 	t.AppendHeader(table.Row{"Property", "Value"})
@@ -218,6 +303,15 @@ func startServer(cmd *cobra.Command, args []string) error {
 
 func stopServer(cmd *cobra.Command, args []string) error {
 	server := args[0]
+
+	if err := checkProtected(server, "stop"); err != nil {
+		return err
+	}
+
+	if err := confirmServerAction(cmd, "stop", server); err != nil {
+		return err
+	}
+
 	res, err := client.StopServer(server)
 	if err != nil {
 		return err
@@ -232,6 +326,15 @@ func stopServer(cmd *cobra.Command, args []string) error {
 
 func deleteServer(cmd *cobra.Command, args []string) error {
 	server := args[0]
+
+	if err := checkProtected(server, "delete"); err != nil {
+		return err
+	}
+
+	if err := confirmServerAction(cmd, "delete", server); err != nil {
+		return err
+	}
+
 	res, err := client.DeleteServer(server)
 	if err != nil {
 		return err
@@ -241,6 +344,8 @@ func deleteServer(cmd *cobra.Command, args []string) error {
 		return errors.New(res.Error)
 	}
 
+	refreshSSHConfig()
+
 	return nil
 }
 
@@ -297,9 +402,47 @@ func deployServer(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	name := args[0]
-	adminUser := args[1]
-	adminPass := args[2]
+	interactive, err := flags.GetBool("interactive")
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 && !interactive {
+		if !isTerminal() {
+			return errors.New("name, admin_user and admin_pass are required when not running on a terminal")
+		}
+		interactive = true
+	}
+
+	var name, adminUser, adminPass string
+	if len(args) >= 2 {
+		name = args[0]
+		adminUser = args[1]
+	}
+
+	if len(args) == 3 {
+		adminPass = args[2]
+		log.Print("warning: passing admin_pass as an argument exposes it in shell history and process listings, consider --adminPassStdin or --adminPassFile")
+	}
+
+	adminPass, generated, err := resolveAdminPass(flags, adminPass, !interactive)
+	if err != nil {
+		return err
+	}
+
+	// check the keys up front so a typo doesn't surface after deploying
+	sshKeys, err := flags.GetStringSlice("sshKey")
+	if err != nil {
+		return err
+	}
+
+	if _, err := readPublicKeys(sshKeys); err != nil {
+		return err
+	}
+
+	if _, err := bootstrapScripts(flags); err != nil {
+		return err
+	}
 
 	req := api.DeployServerRequest{
 		AdminUser:    adminUser,
@@ -314,6 +457,59 @@ func deployServer(cmd *cobra.Command, args []string) error {
 		Name:         name,
 	}
 
+	skipValidation, err := flags.GetBool("skipValidation")
+	if err != nil {
+		return err
+	}
+
+	if interactive {
+		// the wizard deploys exactly what was picked, so options that
+		// choose or wait for hardware don't apply
+		whenAvailable, err := flags.GetBool("whenAvailable")
+		if err != nil {
+			return err
+		}
+
+		if whenAvailable {
+			return errors.New("--whenAvailable can't be used with the interactive wizard, give name, admin_user and admin_pass instead")
+		}
+
+		if isAutoPlacement(location, gpuModel+cpuModel) || flags.Changed("regionPrefix") || flags.Changed("minVram") {
+			return errors.New("automatic placement can't be used with the interactive wizard, give name, admin_user and admin_pass instead")
+		}
+
+		req.GPUModel = gpuModel
+		req.GPUCount = gpuCount
+		req.CPUModel = cpuModel
+
+		confirmed, err := deployWizard(&req)
+		if err != nil {
+			return err
+		}
+
+		if !confirmed {
+			return errors.New("deploy cancelled")
+		}
+
+		if !skipValidation {
+			model := req.GPUModel
+			if req.InstanceType == "cpu" {
+				model = req.CPUModel
+			}
+
+			if err := validateDeployRequest(req, []string{model}, []string{req.Location}, true); err != nil {
+				return err
+			}
+		}
+
+		id, err := submitDeploy(req)
+		if err != nil {
+			return err
+		}
+
+		return finishDeploy(cmd, req, id, generated)
+	}
+
 	switch instanceType {
 	case "cpu":
 		req.CPUModel = cpuModel
@@ -324,17 +520,194 @@ func deployServer(cmd *cobra.Command, args []string) error {
 		return errors.New("unknown instance type")
 	}
 
-	res, err := client.DeployServer(req)
+	whenAvailable, err := flags.GetBool("whenAvailable")
+	if err != nil {
+		return err
+	}
+
+	if !skipValidation {
+		models := parseModelChoice(req.GPUModel + req.CPUModel)
+		checkStock := !whenAvailable && !isAutoPlacement(location, req.GPUModel+req.CPUModel)
+		if err := validateDeployRequest(req, models, splitList(location), checkStock); err != nil {
+			return err
+		}
+	}
+
+	if whenAvailable {
+		if isAutoPlacement(location, req.GPUModel+req.CPUModel) {
+			return errors.New("automatic placement is not supported with --whenAvailable, use fallback lists instead")
+		}
+
+		timeout, err := flags.GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+
+		interval, err := flags.GetDuration("interval")
+		if err != nil {
+			return err
+		}
+
+		model := gpuModel
+		if instanceType == "cpu" {
+			model = cpuModel
+		}
 
+		id, err := deployWhenAvailable(req, splitList(model), splitList(location), interval, timeout)
+		if err != nil {
+			return err
+		}
+
+		return finishDeploy(cmd, req, id, generated)
+	}
+
+	if isAutoPlacement(location, req.GPUModel+req.CPUModel) {
+		minVram, err := flags.GetInt("minVram")
+		if err != nil {
+			return err
+		}
+
+		regionPrefix, err := flags.GetString("regionPrefix")
+		if err != nil {
+			return err
+		}
+
+		err = choosePlacement(cmd, &req, placementConstraints{
+			Models:       parseModelChoice(req.GPUModel + req.CPUModel),
+			Location:     location,
+			RegionPrefix: regionPrefix,
+			MinVRAM:      minVram,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if strings.Contains(req.Location, ",") || strings.Contains(req.GPUModel, ",") || strings.Contains(req.CPUModel, ",") {
+		return errors.New("fallback lists require --whenAvailable")
+	}
+
+	id, err := submitDeploy(req)
 	if err != nil {
 		return err
 	}
 
+	return finishDeploy(cmd, req, id, generated)
+}
+
+func deployArgs(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return cobra.RangeArgs(2, 3)(cmd, args)
+}
+
+func submitDeploy(req api.DeployServerRequest) (string, error) {
+	res, err := client.DeployServer(req)
+
+	if err != nil {
+		return "", err
+	}
+
 	if !res.Success {
-		return errors.New(res.Error)
+		return "", errors.New(res.Error)
+	}
+
+	return res.Server.Id, nil
+}
+
+// addProvisionFlags registers the admin password, SSH key and bootstrap
+// flags used by finishDeploy.
+func addProvisionFlags(flags *pflag.FlagSet) {
+	flags.Bool("adminPassStdin", false, "Read the admin password from standard input")
+	flags.String("adminPassFile", "", "Read the admin password from a file")
+	flags.Bool("generatePassword", false, "Generate a strong admin password")
+	flags.Bool("storePassword", false, "Store the admin password for \"servers password\" instead of printing it (WARNING: saved unencrypted in the state directory next to the config file)")
+	flags.StringSlice("sshKey", nil, "Public key file to install for the admin user once the server is up (repeatable, defaults to sshKeys in the config file)")
+	flags.Bool("disablePasswordAuth", false, "Disable SSH password logins after installing keys")
+	flags.Duration("sshTimeout", 15*time.Minute, "Maximum time to wait for the server to accept SSH connections")
+	flags.Int("port", 22, "SSH port used to set up the server (config: ssh.port)")
+	flags.String("identity", "", "Private key file used to check key logins (config: ssh.identity)")
+	flags.StringSlice("bootstrap", nil, "Script to run on the server once it is up (repeatable)")
+	flags.String("bootstrapDir", "", "Directory of scripts to run on the server once it is up, in lexical order")
+}
+
+// finishDeploy reports the new server and handles the admin password,
+// either storing it or printing it once if it was generated.
+func finishDeploy(cmd *cobra.Command, req api.DeployServerRequest, id string, generated bool) error {
+	if client.DryRun {
+		return nil
+	}
+
+	fmt.Println(id)
+
+	// runs after any provisioning, by which time the server usually has an
+	// IP; if it doesn't yet, the next deploy, delete or "ssh-config sync"
+	// adds it
+	defer refreshSSHConfig()
+
+	storePassword, err := cmd.Flags().GetBool("storePassword")
+	if err != nil {
+		return err
 	}
 
-	fmt.Println(res.Server.Id)
+	if storePassword {
+		// the server exists by now, so don't lose a generated password
+		// just because it couldn't be saved
+		if err := savePassword(id, req.Name, req.AdminUser, req.AdminPass); err != nil {
+			log.Printf("warning: failed to store admin password: %v", err)
+			if generated {
+				log.Printf("admin password: %v", req.AdminPass)
+			}
+		} else {
+			log.Printf("admin password stored unencrypted, retrieve it with \"tensordock-cli servers password %v\"", id)
+		}
+	} else if generated {
+		log.Printf("admin password: %v", req.AdminPass)
+	}
+
+	sshKeys, err := cmd.Flags().GetStringSlice("sshKey")
+	if err != nil {
+		return err
+	}
+
+	keys, err := readPublicKeys(sshKeys)
+	if err != nil {
+		return err
+	}
+
+	disablePasswordAuth, err := cmd.Flags().GetBool("disablePasswordAuth")
+	if err != nil {
+		return err
+	}
+
+	sshTimeout, err := cmd.Flags().GetDuration("sshTimeout")
+	if err != nil {
+		return err
+	}
+
+	scripts, err := bootstrapScripts(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		if err := installPublicKeys(cmd.Flags(), id, req.AdminUser, req.AdminPass, keys, sshTimeout); err != nil {
+			return err
+		}
+	}
+
+	if len(scripts) > 0 {
+		if err := runBootstrap(cmd.Flags(), id, req.AdminUser, req.AdminPass, scripts, sshTimeout); err != nil {
+			return err
+		}
+	}
+
+	// password logins are turned off last so that the bootstrap
+	// scripts can still log in with the admin password
+	if len(keys) > 0 && disablePasswordAuth {
+		return disablePasswordLogins(cmd.Flags(), id, req.AdminUser, req.AdminPass, sshTimeout)
+	}
 
 	return nil
 }
@@ -362,21 +735,24 @@ func sshServer(cmd *cobra.Command, args []string) error {
 	flags := cmd.Flags()
 
 	server := args[0]
-	res, err := client.GetServer(server)
+
+	resetHostKey, err := flags.GetBool("resetHostKey")
 	if err != nil {
 		return err
 	}
 
-	if !res.Success {
-		return errors.New(res.Error)
+	if resetHostKey {
+		if err := removeHostKey(server); err != nil {
+			return err
+		}
 	}
 
-	bin, err := flags.GetString("bin")
+	target, err := sshTargetFor(flags, server)
 	if err != nil {
 		return err
 	}
 
-	user, err := flags.GetString("user")
+	bin, err := flags.GetString("bin")
 	if err != nil {
 		return err
 	}
@@ -386,24 +762,79 @@ func sshServer(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	sshCmd := exec.Command(bin, fmt.Sprintf("%v@%v", user, res.Server.Ip), extraFlags)
-	sshCmd.Stdin = os.Stdin
-	sshCmd.Stdout = os.Stdout
-	sshCmd.Stderr = os.Stderr
+	extraArgs, err := splitArgs(extraFlags)
+	if err != nil {
+		return fmt.Errorf("invalid extra flags: %v", err)
+	}
 
-	if err := sshCmd.Run(); err != nil {
+	native, err := flags.GetBool("native")
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if !flags.Changed("native") {
+		native = viper.GetBool("ssh.native")
+	}
+
+	var command []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		command = args[dash:]
+	}
+
+	if native {
+		conn, err := target.dial()
+		if err == nil {
+			defer conn.Close()
+			return exitWithRemoteStatus(runNativeSSH(conn, command))
+		}
+
+		// a changed host key must never be bypassed by retrying
+		// with a client that may not know about it
+		var changed *hostKeyChangedError
+		if errors.As(err, &changed) {
+			return err
+		}
+
+		log.Printf("warning: built-in client failed (%v), falling back to %v", err, bin)
+	}
+
+	return exitWithRemoteStatus(runExternalSSH(bin, target, extraArgs, command))
+}
+
+// exitWithRemoteStatus turns the remote command's exit status into an
+// exitCodeError so that the CLI exits with it and scripts can rely on it,
+// instead of reporting it as an error.
+func exitWithRemoteStatus(err error) error {
+	var sshErr *ssh.ExitError
+	if errors.As(err, &sshErr) {
+		return &exitCodeError{sshErr.ExitStatus()}
+	}
+
+	var execErr *exec.ExitError
+	if errors.As(err, &execErr) {
+		return &exitCodeError{execErr.ExitCode()}
+	}
+
+	return err
 }
 
 func logAction(message string) func(*cobra.Command, []string) {
-	return func(c *cobra.Command, s []string) { log.Println(message) }
+	return func(c *cobra.Command, s []string) {
+		if client.DryRun {
+			log.Println("dry run, nothing was changed")
+			return
+		}
+		log.Println(message)
+	}
 }
 
 func restartServer(cmd *cobra.Command, args []string) error {
 	server := args[0]
+
+	if err := confirmServerAction(cmd, "restart", server); err != nil {
+		return err
+	}
+
 	res, err := client.RestartServer(server)
 	if err != nil {
 		return err
@@ -416,98 +847,104 @@ func restartServer(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func modifyServer(cmd *cobra.Command, args []string) error {
-	flags := cmd.Flags()
-
-	serverId := args[0]
+// modifySpec is the full configuration the modify endpoint expects.
+type modifySpec struct {
+	InstanceType string
+	GPUModel     string
+	GPUCount     int
+	CPUModel     string
+	VCPUs        int
+	RAM          int
+	Storage      int
+}
 
-	var instanceType *string = nil
-	if flags.Changed("instanceType") {
-		instanceTypeVal, err := flags.GetString("instanceType")
-		if err != nil {
-			return err
+func currentModifySpec(server api.Server) modifySpec {
+	instanceType := server.Type
+	if instanceType == "" {
+		instanceType = "cpu"
+		if server.GPUCount > 0 {
+			instanceType = "gpu"
 		}
-		instanceType = &instanceTypeVal
 	}
 
-	var gpuModel *string = nil
-	if flags.Changed("gpuModel") {
-		gpuModelVal, err := flags.GetString("gpuModel")
-		if err != nil {
-			return err
-		}
-		gpuModel = &gpuModelVal
+	return modifySpec{
+		InstanceType: instanceType,
+		GPUModel:     server.GPUModel,
+		GPUCount:     server.GPUCount,
+		CPUModel:     server.CPUModel,
+		VCPUs:        server.VCPUs,
+		RAM:          server.Ram,
+		Storage:      server.Storage,
 	}
+}
 
-	var gpuCount *int = nil
-	if flags.Changed("gpuCount") {
-		gpuCountVal, err := flags.GetInt("gpuCount")
-		if err != nil {
-			return err
-		}
-		gpuCount = &gpuCountVal
+// fields lists the parts of the spec that apply to its instance type.
+func (spec modifySpec) fields() map[string]string {
+	fields := map[string]string{
+		"Instance type": spec.InstanceType,
+		"vCPUs":         strconv.Itoa(spec.VCPUs),
+		"RAM":           fmt.Sprintf("%vGB", spec.RAM),
+		"Storage":       fmt.Sprintf("%vGB", spec.Storage),
 	}
 
-	var cpuModel *string = nil
-	if flags.Changed("cpuModel") {
-		cpuModelVal, err := flags.GetString("cpuModel")
-		if err != nil {
-			return err
-		}
-		cpuModel = &cpuModelVal
+	if spec.InstanceType == "gpu" {
+		fields["GPU model"] = spec.GPUModel
+		fields["GPU count"] = strconv.Itoa(spec.GPUCount)
+	} else {
+		fields["CPU model"] = spec.CPUModel
 	}
 
-	var vcpus *int = nil
-	if flags.Changed("vcpus") {
-		vcpusVal, err := flags.GetInt("vcpus")
-		if err != nil {
-			return err
-		}
-		vcpus = &vcpusVal
-	}
+	return fields
+}
 
-	var ram *int = nil
-	if flags.Changed("ram") {
-		ramVal, err := flags.GetInt("ram")
-		if err != nil {
-			return err
+// diff returns a row for every field that differs between the two specs.
+func (spec modifySpec) diff(other modifySpec) []table.Row {
+	before, after := spec.fields(), other.fields()
+
+	rows := []table.Row{}
+	for _, name := range []string{"Instance type", "GPU model", "GPU count", "CPU model", "vCPUs", "RAM", "Storage"} {
+		if before[name] == after[name] {
+			continue
 		}
-		ram = &ramVal
-	}
 
-	var storage *int = nil
-	if flags.Changed("storage") {
-		storageVal, err := flags.GetInt("storage")
-		if err != nil {
-			return err
+		from, to := before[name], after[name]
+		if from == "" {
+			from = "-"
+		}
+		if to == "" {
+			to = "-"
 		}
-		storage = &storageVal
+		rows = append(rows, table.Row{name, from, to})
 	}
 
-	// based on tests, it seems that the endpoint does not
-	// support specifying only parts of the spec to modify
-	// (e.g. adjust VCPUs only) and that you need to specify
-	// the entirety of the server configuration on every call
+	return rows
+}
+
+func (spec modifySpec) request(serverId string) api.ModifyServerRequest {
 	req := api.ModifyServerRequest{
 		ServerId:     serverId,
-		InstanceType: instanceType,
-		VCPUs:        vcpus,
-		RAM:          ram,
-		Storage:      storage,
+		InstanceType: &spec.InstanceType,
+		VCPUs:        &spec.VCPUs,
+		RAM:          &spec.RAM,
+		Storage:      &spec.Storage,
 	}
 
-	switch *instanceType {
-	case "cpu":
-		req.CPUModel = cpuModel
-	case "gpu":
-		req.GPUModel = gpuModel
-		req.GPUCount = gpuCount
-	default:
-		return errors.New("unknown instance type")
+	if spec.InstanceType == "gpu" {
+		req.GPUModel = &spec.GPUModel
+		req.GPUCount = &spec.GPUCount
+	} else {
+		req.CPUModel = &spec.CPUModel
 	}
 
-	res, err := client.ModifyServer(req)
+	return req
+}
+
+func modifyServer(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	serverId := args[0]
 
+	res, err := client.GetServer(serverId)
 	if err != nil {
 		return err
 	}
@@ -516,21 +953,114 @@ func modifyServer(cmd *cobra.Command, args []string) error {
 		return errors.New(res.Error)
 	}
 
-	return nil
-}
+	// the endpoint does not support specifying only parts of the spec
+	// (e.g. adjust VCPUs only) so the flags that were given are merged
+	// into the current configuration and the whole of it is sent
+	current := currentModifySpec(res.Server)
+	spec := current
 
-func serverStatus(cmd *cobra.Command, args []string) error {
-	server := args[0]
-	res, err := client.GetServerStatus(server)
+	stringFlags := map[string]*string{
+		"instanceType": &spec.InstanceType,
+		"gpuModel":     &spec.GPUModel,
+		"cpuModel":     &spec.CPUModel,
+	}
+	for name, field := range stringFlags {
+		if !flags.Changed(name) {
+			continue
+		}
+		if *field, err = flags.GetString(name); err != nil {
+			return err
+		}
+	}
+
+	intFlags := map[string]*int{
+		"gpuCount": &spec.GPUCount,
+		"vcpus":    &spec.VCPUs,
+		"ram":      &spec.RAM,
+		"storage":  &spec.Storage,
+	}
+	for name, field := range intFlags {
+		if !flags.Changed(name) {
+			continue
+		}
+		if *field, err = flags.GetInt(name); err != nil {
+			return err
+		}
+	}
+
+	changes := current.diff(spec)
+	if len(changes) == 0 {
+		return errors.New("nothing to modify, the server already has this configuration")
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"", "Current", "New"})
+	t.AppendRows(changes)
+	t.Render()
+
+	req := spec.request(serverId)
+
+	skipValidation, err := flags.GetBool("skipValidation")
 	if err != nil {
 		return err
 	}
 
-	if !res.Success {
-		return errors.New(res.Error)
+	if !skipValidation {
+		if err := validateModifyRequest(req); err != nil {
+			return err
+		}
+	}
+
+	if err := confirmServerAction(cmd, "modify", serverId); err != nil {
+		return err
 	}
 
-	fmt.Println(res.Status)
+	modifyRes, err := client.ModifyServer(req)
+
+	if err != nil {
+		return err
+	}
+
+	if !modifyRes.Success {
+		return errors.New(modifyRes.Error)
+	}
 
 	return nil
 }
+
+type serverStatusEntry struct {
+	Id     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func serverStatus(cmd *cobra.Command, args []string) error {
+	server := args[0]
+
+	return watchSpec[serverStatusEntry]{
+		fetch: func() ([]serverStatusEntry, error) {
+			res, err := client.GetServerStatus(server)
+			if err != nil {
+				return nil, err
+			}
+
+			if !res.Success {
+				return nil, errors.New(res.Error)
+			}
+
+			return []serverStatusEntry{{server, res.Status}}, nil
+		},
+		key: func(entry serverStatusEntry) string {
+			return entry.Id
+		},
+		render: func(entries []serverStatusEntry) error {
+			for _, entry := range entries {
+				fmt.Println(entry.Status)
+			}
+			return nil
+		},
+		change: func(before serverStatusEntry, after serverStatusEntry) (string, interface{}) {
+			return "status_changed", nil
+		},
+	}.run(cmd.Flags())
+}
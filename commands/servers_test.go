@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+)
+
+func TestCurrentModifySpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		server api.Server
+		want   modifySpec
+	}{
+		{
+			"gpu server",
+			api.Server{Type: "gpu", GPUModel: "A4000", GPUCount: 2, VCPUs: 8, Ram: 32, Storage: 100},
+			modifySpec{InstanceType: "gpu", GPUModel: "A4000", GPUCount: 2, VCPUs: 8, RAM: 32, Storage: 100},
+		},
+		{
+			"cpu server",
+			api.Server{Type: "cpu", CPUModel: "Intel_Xeon_v4", VCPUs: 4, Ram: 8, Storage: 20},
+			modifySpec{InstanceType: "cpu", CPUModel: "Intel_Xeon_v4", VCPUs: 4, RAM: 8, Storage: 20},
+		},
+		{
+			"missing type with gpus",
+			api.Server{GPUModel: "A5000", GPUCount: 1, VCPUs: 4, Ram: 16, Storage: 50},
+			modifySpec{InstanceType: "gpu", GPUModel: "A5000", GPUCount: 1, VCPUs: 4, RAM: 16, Storage: 50},
+		},
+		{
+			"missing type without gpus",
+			api.Server{CPUModel: "Intel_Xeon_v4", VCPUs: 2, Ram: 4, Storage: 20},
+			modifySpec{InstanceType: "cpu", CPUModel: "Intel_Xeon_v4", VCPUs: 2, RAM: 4, Storage: 20},
+		},
+	}
+
+	for _, test := range tests {
+		if got := currentModifySpec(test.server); got != test.want {
+			t.Errorf("%v: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestModifySpecDiff(t *testing.T) {
+	gpu := modifySpec{InstanceType: "gpu", GPUModel: "A4000", GPUCount: 2, VCPUs: 8, RAM: 32, Storage: 100}
+	cpu := modifySpec{InstanceType: "cpu", CPUModel: "Intel_Xeon_v4", VCPUs: 8, RAM: 32, Storage: 100}
+
+	tests := []struct {
+		name   string
+		before modifySpec
+		modify func(spec *modifySpec)
+		want   []string
+	}{
+		{"unchanged", gpu, func(spec *modifySpec) {}, nil},
+		{"ram", gpu, func(spec *modifySpec) { spec.RAM = 64 }, []string{"RAM: 32GB -> 64GB"}},
+		{"gpu count and storage", gpu, func(spec *modifySpec) { spec.GPUCount, spec.Storage = 4, 200 }, []string{
+			"GPU count: 2 -> 4",
+			"Storage: 100GB -> 200GB",
+		}},
+		{"cpu model ignored on gpu servers", gpu, func(spec *modifySpec) { spec.CPUModel = "Intel_Xeon_v4" }, nil},
+		{"gpu fields ignored on cpu servers", cpu, func(spec *modifySpec) { spec.GPUModel, spec.GPUCount = "A4000", 1 }, nil},
+		{"gpu to cpu", gpu, func(spec *modifySpec) { *spec = cpu }, []string{
+			"Instance type: gpu -> cpu",
+			"GPU model: A4000 -> -",
+			"GPU count: 2 -> -",
+			"CPU model: - -> Intel_Xeon_v4",
+		}},
+		{"cpu to gpu", cpu, func(spec *modifySpec) { *spec = gpu }, []string{
+			"Instance type: cpu -> gpu",
+			"GPU model: - -> A4000",
+			"GPU count: - -> 2",
+			"CPU model: Intel_Xeon_v4 -> -",
+		}},
+	}
+
+	for _, test := range tests {
+		after := test.before
+		test.modify(&after)
+
+		rows := test.before.diff(after)
+		if len(rows) != len(test.want) {
+			t.Errorf("%v: got rows %v, want %v", test.name, rows, test.want)
+			continue
+		}
+
+		for i, row := range rows {
+			if got := fmt.Sprintf("%v: %v -> %v", row[0], row[1], row[2]); got != test.want[i] {
+				t.Errorf("%v: row %v is %q, want %q", test.name, i, got, test.want[i])
+			}
+		}
+	}
+}
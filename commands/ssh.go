@@ -0,0 +1,595 @@
+package commands
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+// sshTarget describes how to reach a server over SSH. Host keys are tracked
+// per server ID rather than per IP, since IPs get reused between servers.
+type sshTarget struct {
+	ID       string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Identity string
+}
+
+// addSSHFlags registers the connection flags shared by every command that
+// talks to servers over SSH. Defaults can be set in the config file under
+// the "ssh" key.
+func addSSHFlags(flags *pflag.FlagSet) {
+	flags.String("user", "user", "User account to use for login (config: ssh.user)")
+	flags.Int("port", 22, "SSH port (config: ssh.port)")
+	flags.String("identity", "", "Private key file to authenticate with (config: ssh.identity)")
+}
+
+func sshSetting(flags *pflag.FlagSet, name string) string {
+	if !flags.Changed(name) && viper.IsSet("ssh."+name) {
+		return viper.GetString("ssh." + name)
+	}
+	value, _ := flags.GetString(name)
+	return value
+}
+
+// sshTargetFor resolves a server's address and the connection settings from
+// the flags, the config file and any stored admin password.
+func sshTargetFor(flags *pflag.FlagSet, id string) (*sshTarget, error) {
+	res, err := client.GetServer(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.Success {
+		return nil, errors.New(res.Error)
+	}
+
+	if res.Server.Ip == "" {
+		return nil, fmt.Errorf("server %v has no IP address", id)
+	}
+
+	return sshTargetFromFlags(flags, id, res.Server.Ip)
+}
+
+// sshPort returns the --port flag if given, then ssh.port from the config
+// file, then the flag's default or 22 for commands without the flag.
+func sshPort(flags *pflag.FlagSet) (int, error) {
+	if flags.Lookup("port") == nil {
+		if viper.IsSet("ssh.port") {
+			return viper.GetInt("ssh.port"), nil
+		}
+		return 22, nil
+	}
+
+	if !flags.Changed("port") && viper.IsSet("ssh.port") {
+		return viper.GetInt("ssh.port"), nil
+	}
+
+	return flags.GetInt("port")
+}
+
+func sshTargetFromFlags(flags *pflag.FlagSet, id string, host string) (*sshTarget, error) {
+	port, err := sshPort(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &sshTarget{
+		ID:       id,
+		Host:     host,
+		Port:     port,
+		User:     sshSetting(flags, "user"),
+		Identity: expandHome(sshSetting(flags, "identity")),
+	}
+
+	passwords, err := loadPasswords()
+	if err != nil {
+		return nil, err
+	}
+
+	if stored, ok := passwords[id]; ok && stored.AdminUser == target.User {
+		target.Password = stored.AdminPass
+	}
+
+	return target, nil
+}
+
+func (target *sshTarget) address() string {
+	return net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+}
+
+func (target *sshTarget) destination() string {
+	return fmt.Sprintf("%v@%v", target.User, target.Host)
+}
+
+// waitForSSH waits until the server is running and accepts connections on
+// the SSH port given by --port or the config file (22 by default). The
+// returned target has no user or password set.
+func waitForSSH(flags *pflag.FlagSet, id string, timeout time.Duration) (*sshTarget, error) {
+	deadline := time.Now().Add(timeout)
+
+	port, err := sshPort(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		res, err := client.GetServer(id)
+		if err == nil && res.Success && isRunning(res.Server) && res.Server.Ip != "" {
+			target := &sshTarget{
+				ID:       id,
+				Host:     res.Server.Ip,
+				Port:     port,
+				Identity: expandHome(sshSetting(flags, "identity")),
+			}
+
+			conn, err := net.DialTimeout("tcp", target.address(), 10*time.Second)
+			if err == nil {
+				conn.Close()
+				return target, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %v to accept SSH connections", id)
+		}
+
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// knownHostsMu serializes access to the known hosts file when connecting to
+// several servers at once.
+var knownHostsMu sync.Mutex
+
+func knownHostsPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "known_hosts"), nil
+}
+
+// knownHostMatches reports whether a known_hosts host pattern refers to the
+// server ID, including hashed entries written with HashKnownHosts.
+func knownHostMatches(pattern string, id string) bool {
+	if !strings.HasPrefix(pattern, "|1|") {
+		return pattern == id
+	}
+
+	parts := strings.Split(pattern[len("|1|"):], "|")
+	if len(parts) != 2 {
+		return false
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(id))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// knownHostKeyTypes returns the types of the keys stored for a server. Lines
+// that can't be parsed are skipped rather than hiding the ones after them.
+func knownHostKeyTypes(path string, id string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	types := []string{}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		marker, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil || marker != "" || seen[key.Type()] {
+			continue
+		}
+
+		for _, host := range hosts {
+			if knownHostMatches(host, id) {
+				types = append(types, key.Type())
+				seen[key.Type()] = true
+				break
+			}
+		}
+	}
+
+	return types, nil
+}
+
+// hostKeyAlgorithms lists the algorithms to ask the server for so that it
+// presents a key of a type we already know instead of one the client
+// happens to prefer. It returns nil, meaning the client defaults, for
+// servers without known keys.
+func hostKeyAlgorithms(keyTypes []string) []string {
+	var algorithms []string
+	for _, keyType := range keyTypes {
+		if keyType == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, keyType)
+	}
+	return algorithms
+}
+
+// hostKeyChangedError is returned when a server presents a different host
+// key than the one remembered for it. Callers must not retry the connection
+// with another client when they see it.
+type hostKeyChangedError struct {
+	ID          string
+	Fingerprint string
+}
+
+func (err *hostKeyChangedError) Error() string {
+	return fmt.Sprintf("host key for server %v has changed (now %v), if this is expected run \"tensordock-cli servers ssh --resetHostKey %v\"", err.ID, err.Fingerprint, err.ID)
+}
+
+// hostKeyCallback checks host keys against the known hosts file, trusting
+// the first key seen for a server ID and rejecting any different key
+// afterwards. The file uses the OpenSSH known_hosts format with server IDs
+// as host names so the external client can share it through HostKeyAlias.
+// It also returns the algorithms of the keys already known for the server.
+func hostKeyCallback(id string) (ssh.HostKeyCallback, []string, error) {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	path, err := knownHostsPath()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	file.Close()
+
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyTypes, err := knownHostKeyTypes(path, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		// the alias has no port, which known_hosts treats as port 22
+		err := check(net.JoinHostPort(id, "22"), remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			return &hostKeyChangedError{id, ssh.FingerprintSHA256(key)}
+		}
+
+		return addHostKey(path, id, key)
+	}
+
+	return callback, hostKeyAlgorithms(keyTypes), nil
+}
+
+func addHostKey(path string, id string, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintln(file, knownhosts.Line([]string{id}, key)); err != nil {
+		return err
+	}
+
+	log.Printf("added host key %v for server %v", ssh.FingerprintSHA256(key), id)
+
+	return nil
+}
+
+func removeHostKey(id string) error {
+	path, err := knownHostsPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	kept := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+			fields = fields[1:]
+		}
+
+		if line == "" || (len(fields) > 0 && matchesAnyHost(fields[0], id)) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(kept, "\n")+"\n"), 0600)
+}
+
+func matchesAnyHost(patterns string, id string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		if knownHostMatches(pattern, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// dial connects using the password if one is known for the server, then
+// the configured identity, the default private keys in ~/.ssh and the SSH
+// agent. The password goes first so that offering many keys to a freshly
+// deployed server doesn't run into sshd's MaxAuthTries before it is tried.
+func (target *sshTarget) dial() (*ssh.Client, error) {
+	auth := []ssh.AuthMethod{}
+	signers := []ssh.Signer{}
+
+	if target.Password != "" {
+		auth = append(auth, ssh.Password(target.Password))
+	}
+
+	keyFiles := []string{}
+	if target.Identity != "" {
+		keyFiles = append(keyFiles, target.Identity)
+	}
+
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		keyFiles = append(keyFiles, expandHome(filepath.Join("~/.ssh", name)))
+	}
+
+	for i, path := range keyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if i == 0 && target.Identity != "" {
+				return nil, err
+			}
+			continue
+		}
+
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			if i == 0 && target.Identity != "" {
+				return nil, fmt.Errorf("failed to load %v: %v", path, err)
+			}
+			continue
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+		}
+	}
+
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+
+	callback, algorithms, err := hostKeyCallback(target.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the ssh package flattens callback errors into strings, so keep a
+	// changed host key aside to return it as is
+	var changed *hostKeyChangedError
+	config := &ssh.ClientConfig{
+		User:    target.User,
+		Auth:    auth,
+		Timeout: 15 * time.Second,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			err := callback(hostname, remote, key)
+			errors.As(err, &changed)
+			return err
+		},
+		HostKeyAlgorithms: algorithms,
+	}
+
+	conn, err := ssh.Dial("tcp", target.address(), config)
+	if changed != nil {
+		return nil, changed
+	}
+
+	return conn, err
+}
+
+// externalArgs builds the arguments for the external SSH client. Options
+// only understood by OpenSSH are skipped for other clients such as mosh.
+func (target *sshTarget) externalArgs(bin string, extraFlags []string, command []string) ([]string, error) {
+	args := append([]string{}, extraFlags...)
+
+	if filepath.Base(bin) == "ssh" {
+		path, err := knownHostsPath()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args,
+			"-o", "HostKeyAlias="+target.ID,
+			"-o", "UserKnownHostsFile="+path,
+			"-o", "StrictHostKeyChecking=accept-new",
+			"-p", strconv.Itoa(target.Port))
+
+		if target.Identity != "" {
+			args = append(args, "-i", target.Identity)
+		}
+	}
+
+	args = append(args, target.destination())
+	if len(command) > 0 {
+		args = append(args, "--")
+		args = append(args, command...)
+	}
+
+	return args, nil
+}
+
+func runExternalSSH(bin string, target *sshTarget, extraFlags []string, command []string) error {
+	args, err := target.externalArgs(bin, extraFlags, command)
+	if err != nil {
+		return err
+	}
+
+	sshCmd := exec.Command(bin, args...)
+	sshCmd.Stdin = os.Stdin
+	sshCmd.Stdout = os.Stdout
+	sshCmd.Stderr = os.Stderr
+
+	return sshCmd.Run()
+}
+
+// runNativeSSH opens an interactive shell, or runs the command if one is
+// given, using the built-in client.
+func runNativeSSH(conn *ssh.Client, command []string) error {
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	if len(command) > 0 {
+		return session.Run(strings.Join(command, " "))
+	}
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+
+		if err := session.RequestPty(termType, height, width, ssh.TerminalModes{}); err != nil {
+			return err
+		}
+	}
+
+	if err := session.Shell(); err != nil {
+		return err
+	}
+
+	return session.Wait()
+}
+
+// splitArgs splits a string into arguments the way a POSIX shell would,
+// honouring single quotes, double quotes and backslash escapes.
+func splitArgs(value string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\'':
+			end := strings.IndexByte(value[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			current.WriteString(value[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+
+		case c == '"':
+			i++
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) && strings.IndexByte("\"\\$`", value[i+1]) >= 0 {
+					i++
+				}
+				current.WriteByte(value[i])
+			}
+			if i >= len(value) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inArg = true
+
+		case c == '\\':
+			if i+1 < len(value) {
+				i++
+				current.WriteByte(value[i])
+			}
+			inArg = true
+
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{}, false},
+		{"  ", []string{}, false},
+		{"-A -o ServerAliveInterval=30", []string{"-A", "-o", "ServerAliveInterval=30"}, false},
+		{"-o 'ProxyJump bastion host'", []string{"-o", "ProxyJump bastion host"}, false},
+		{`-o "User=\"deploy\""`, []string{"-o", `User="deploy"`}, false},
+		{`-o "a\b"`, []string{"-o", `a\b`}, false},
+		{`one\ arg two`, []string{"one arg", "two"}, false},
+		{`''`, []string{""}, false},
+		{"a'b'c\"d\"", []string{"abcd"}, false},
+		{"-o 'unterminated", nil, true},
+		{`-o "unterminated`, nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := splitArgs(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("splitArgs(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestKnownHostMatches(t *testing.T) {
+	salt := []byte("0123456789abcdefghij")
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte("s1"))
+	hashed := "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		pattern string
+		id      string
+		want    bool
+	}{
+		{"s1", "s1", true},
+		{"s1", "s2", false},
+		{hashed, "s1", true},
+		{hashed, "s2", false},
+		{"|1|not-base64|x", "s1", false},
+		{"|1|onlysalt", "s1", false},
+	}
+
+	for _, test := range tests {
+		if got := knownHostMatches(test.pattern, test.id); got != test.want {
+			t.Errorf("knownHostMatches(%q, %q) = %v, want %v", test.pattern, test.id, got, test.want)
+		}
+	}
+}
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	tests := []struct {
+		keyTypes []string
+		want     []string
+	}{
+		{nil, nil},
+		{[]string{ssh.KeyAlgoED25519}, []string{ssh.KeyAlgoED25519}},
+		{[]string{ssh.KeyAlgoRSA}, []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}},
+	}
+
+	for _, test := range tests {
+		if got := hostKeyAlgorithms(test.keyTypes); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("hostKeyAlgorithms(%v) = %v, want %v", test.keyTypes, got, test.want)
+		}
+	}
+}
+
+func TestHostKeyChangedError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"direct", &hostKeyChangedError{"s1", "SHA256:abc"}, true},
+		{"wrapped", fmt.Errorf("connecting: %w", &hostKeyChangedError{"s1", "SHA256:abc"}), true},
+		{"other error", errors.New("host key mismatch"), false},
+	}
+
+	for _, test := range tests {
+		var changed *hostKeyChangedError
+		if got := errors.As(test.err, &changed); got != test.want {
+			t.Errorf("%v: errors.As = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const sshConfigHeader = "# Managed by tensordock-cli, changes will be overwritten by \"tensordock-cli ssh-config sync\".\n"
+
+var (
+	sshConfigCmd = &cobra.Command{
+		Use:   "ssh-config",
+		Short: "Generate OpenSSH config entries for servers",
+	}
+	sshConfigGenerateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Print Host blocks for all servers",
+		RunE:  generateSSHConfig,
+	}
+	sshConfigSyncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "Update the managed SSH config include file",
+		Long: `Writes Host blocks for all servers to a file meant to be included from
+~/.ssh/config. Once the file exists it is also refreshed after deploying or
+deleting servers.`,
+		RunE: syncSSHConfig,
+	}
+
+	nonAliasChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{sshConfigGenerateCmd, sshConfigSyncCmd} {
+		cmd.Flags().String("prefix", "", "Prefix for host aliases (config: sshConfig.prefix)")
+		addSSHFlags(cmd.Flags())
+		sshConfigCmd.AddCommand(cmd)
+	}
+
+	sshConfigSyncCmd.Flags().String("file", "~/.ssh/tensordock_config", "Managed file to write (config: sshConfig.file)")
+	sshConfigSyncCmd.Flags().Bool("addInclude", false, "Add an Include line for the managed file to ~/.ssh/config if missing")
+
+	rootCmd.AddCommand(sshConfigCmd)
+}
+
+// sshConfigOptions are the settings the managed file was written with.
+// File is only set for "ssh-config sync", since "generate" prints to stdout.
+// They are saved by "ssh-config sync" so that automatic refreshes produce
+// the same file.
+type sshConfigOptions struct {
+	File     string `json:"file"`
+	Prefix   string `json:"prefix"`
+	User     string `json:"user"`
+	Port     int    `json:"port"`
+	Identity string `json:"identity"`
+}
+
+func sshConfigSetting(flags *pflag.FlagSet, name string) string {
+	if !flags.Changed(name) && viper.IsSet("sshConfig."+name) {
+		return viper.GetString("sshConfig." + name)
+	}
+	value, _ := flags.GetString(name)
+	return value
+}
+
+func sshConfigOptionsFromFlags(flags *pflag.FlagSet) (sshConfigOptions, error) {
+	port, err := flags.GetInt("port")
+	if err != nil {
+		return sshConfigOptions{}, err
+	}
+
+	if !flags.Changed("port") && viper.IsSet("ssh.port") {
+		port = viper.GetInt("ssh.port")
+	}
+
+	return sshConfigOptions{
+		Prefix:   sshConfigSetting(flags, "prefix"),
+		User:     sshSetting(flags, "user"),
+		Port:     port,
+		Identity: expandHome(sshSetting(flags, "identity")),
+	}, nil
+}
+
+// hostAliases derives an alias from each server's name, falling back to the
+// ID for unnamed servers and appending the ID when names collide.
+func hostAliases(servers []api.Server, prefix string) map[string]string {
+	aliases := map[string]string{}
+	counts := map[string]int{}
+
+	base := func(server api.Server) string {
+		alias := strings.Trim(nonAliasChars.ReplaceAllString(strings.ToLower(server.Name), "-"), "-")
+		if alias == "" {
+			alias = server.Id
+		}
+		return prefix + alias
+	}
+
+	for _, server := range servers {
+		counts[base(server)]++
+	}
+
+	for _, server := range servers {
+		alias := base(server)
+		if counts[alias] > 1 {
+			alias += "-" + server.Id
+		}
+		aliases[server.Id] = alias
+	}
+
+	return aliases
+}
+
+// renderSSHConfig writes a Host block for every server with an IP address.
+// Host keys are checked against the same known_hosts file as "servers ssh".
+func renderSSHConfig(servers map[string]api.Server, options sshConfigOptions) ([]byte, error) {
+	knownHosts, err := knownHostsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := []api.Server{}
+	for _, server := range servers {
+		if server.Ip != "" {
+			sorted = append(sorted, server)
+		}
+	}
+
+	aliases := hostAliases(sorted, options.Prefix)
+	sort.Slice(sorted, func(i, j int) bool {
+		return aliases[sorted[i].Id] < aliases[sorted[j].Id]
+	})
+
+	var buf bytes.Buffer
+	for i, server := range sorted {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+
+		fmt.Fprintf(&buf, "# %v (%v)\n", serverLabel(server), server.Id)
+		fmt.Fprintf(&buf, "Host %v\n", aliases[server.Id])
+		fmt.Fprintf(&buf, "    HostName %v\n", server.Ip)
+		fmt.Fprintf(&buf, "    User %v\n", options.User)
+		if options.Port != 22 {
+			fmt.Fprintf(&buf, "    Port %v\n", options.Port)
+		}
+		if options.Identity != "" {
+			fmt.Fprintf(&buf, "    IdentityFile %v\n", options.Identity)
+		}
+		fmt.Fprintf(&buf, "    HostKeyAlias %v\n", server.Id)
+		fmt.Fprintf(&buf, "    UserKnownHostsFile %v\n", knownHosts)
+		fmt.Fprintf(&buf, "    StrictHostKeyChecking accept-new\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func generateSSHConfig(cmd *cobra.Command, args []string) error {
+	options, err := sshConfigOptionsFromFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	servers, err := listServers()
+	if err != nil {
+		return err
+	}
+
+	config, err := renderSSHConfig(servers, options)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(config)
+	return err
+}
+
+// managedAddresses maps the server IDs in a managed file to their IPs.
+func managedAddresses(config []byte) map[string]string {
+	addresses := map[string]string{}
+	ip := ""
+	for _, line := range strings.Split(string(config), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "Host":
+			ip = ""
+		case len(fields) == 2 && fields[0] == "HostName":
+			ip = fields[1]
+		case len(fields) == 2 && fields[0] == "HostKeyAlias":
+			addresses[fields[1]] = ip
+		}
+	}
+	return addresses
+}
+
+func hostNames(config []byte) map[string]string {
+	hosts := map[string]string{}
+	current := ""
+	for _, line := range strings.Split(string(config), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "Host":
+			current = fields[1]
+		case len(fields) == 2 && fields[0] == "HostName" && current != "":
+			hosts[current] = fields[1]
+		}
+	}
+	return hosts
+}
+
+// writeSSHConfig renders the managed file and returns a short summary of
+// the hosts that were added, removed or changed.
+func writeSSHConfig(servers map[string]api.Server, options sshConfigOptions) (string, error) {
+	path := options.File
+
+	config, err := renderSSHConfig(servers, options)
+	if err != nil {
+		return "", err
+	}
+
+	old, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(path, append([]byte(sshConfigHeader+"\n"), config...), 0600); err != nil {
+		return "", err
+	}
+
+	before, after := hostNames(old), hostNames(config)
+	added, removed, changed := 0, 0, 0
+	for host, ip := range after {
+		if prev, ok := before[host]; !ok {
+			added++
+		} else if prev != ip {
+			changed++
+		}
+	}
+	for host := range before {
+		if _, ok := after[host]; !ok {
+			removed++
+		}
+	}
+
+	return fmt.Sprintf("%v host(s): %v added, %v removed, %v changed", len(after), added, removed, changed), nil
+}
+
+// ensureInclude prepends an Include line to ~/.ssh/config, since Include
+// only applies to hosts when it comes before the first Host block.
+func ensureInclude(path string, add bool) error {
+	userConfig := expandHome("~/.ssh/config")
+
+	data, err := os.ReadFile(userConfig)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.EqualFold(fields[0], "Include") {
+			for _, included := range fields[1:] {
+				if expandHome(included) == path || filepath.Join(filepath.Dir(userConfig), included) == path {
+					return nil
+				}
+			}
+		}
+	}
+
+	if !add {
+		log.Printf("add \"Include %v\" to the top of %v, or rerun with --addInclude", path, userConfig)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(userConfig), 0700); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(userConfig, append([]byte(fmt.Sprintf("Include %v\n\n", path)), data...), 0600); err != nil {
+		return err
+	}
+
+	log.Printf("added Include for %v to %v", path, userConfig)
+
+	return nil
+}
+
+func syncSSHConfig(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	addInclude, err := flags.GetBool("addInclude")
+	if err != nil {
+		return err
+	}
+
+	options, err := sshConfigOptionsFromFlags(flags)
+	if err != nil {
+		return err
+	}
+	options.File = expandHome(sshConfigSetting(flags, "file"))
+
+	servers, err := listServers()
+	if err != nil {
+		return err
+	}
+
+	summary, err := writeSSHConfig(servers, options)
+	if err != nil {
+		return err
+	}
+
+	if err := writeState("sshconfig.json", options); err != nil {
+		return err
+	}
+
+	log.Printf("wrote %v (%v)", options.File, summary)
+
+	return ensureInclude(options.File, addInclude)
+}
+
+// loadSSHConfigOptions returns the options of the last "ssh-config sync",
+// falling back to the config file and defaults for files synced before the
+// options were saved. ok is false if there is no managed file.
+func loadSSHConfigOptions() (options sshConfigOptions, ok bool, err error) {
+	if err := readState("sshconfig.json", &options); err != nil {
+		return options, false, err
+	}
+
+	if options.File == "" {
+		flags := pflag.NewFlagSet("ssh-config", pflag.ContinueOnError)
+		flags.String("prefix", "", "")
+		flags.String("file", "~/.ssh/tensordock_config", "")
+		addSSHFlags(flags)
+
+		if options, err = sshConfigOptionsFromFlags(flags); err != nil {
+			return options, false, err
+		}
+		options.File = expandHome(sshConfigSetting(flags, "file"))
+	}
+
+	if _, err := os.Stat(options.File); err != nil {
+		return options, false, nil
+	}
+
+	return options, true, nil
+}
+
+// refreshSSHConfig rewrites the managed file after servers are deployed or
+// deleted, but only if "ssh-config sync" has been used before and the
+// servers' addresses no longer match the file. Failures are reported
+// without failing the command that changed the servers.
+func refreshSSHConfig() {
+	options, ok, err := loadSSHConfigOptions()
+	if err != nil {
+		log.Printf("warning: failed to read ssh-config options: %v", err)
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	old, err := os.ReadFile(options.File)
+	if err != nil {
+		return
+	}
+
+	servers, err := listServers()
+	if err != nil {
+		log.Printf("warning: failed to update SSH config: %v", err)
+		return
+	}
+
+	if !sshConfigStale(managedAddresses(old), servers) {
+		return
+	}
+
+	if _, err := writeSSHConfig(servers, options); err != nil {
+		log.Printf("warning: failed to update %v: %v", options.File, err)
+	}
+}
+
+// sshConfigStale reports whether the addresses in a managed file differ
+// from the servers' current ones.
+func sshConfigStale(managed map[string]string, servers map[string]api.Server) bool {
+	current := 0
+	for id, server := range servers {
+		if server.Ip == "" {
+			continue
+		}
+
+		current++
+		if managed[id] != server.Ip {
+			return true
+		}
+	}
+
+	return len(managed) != current
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/spf13/viper"
+)
+
+func TestHostAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []api.Server
+		prefix  string
+		want    map[string]string
+	}{
+		{"names", []api.Server{{Id: "s1", Name: "Train 01"}, {Id: "s2", Name: "eval_box.v2"}}, "", map[string]string{
+			"s1": "train-01",
+			"s2": "eval_box.v2",
+		}},
+		{"prefix", []api.Server{{Id: "s1", Name: "train"}}, "td-", map[string]string{"s1": "td-train"}},
+		{"unnamed", []api.Server{{Id: "s1", Name: "!!!"}, {Id: "s2"}}, "td-", map[string]string{
+			"s1": "td-s1",
+			"s2": "td-s2",
+		}},
+		{"collisions", []api.Server{{Id: "s1", Name: "train"}, {Id: "s2", Name: "Train"}, {Id: "s3", Name: "eval"}}, "", map[string]string{
+			"s1": "train-s1",
+			"s2": "train-s2",
+			"s3": "eval",
+		}},
+	}
+
+	for _, test := range tests {
+		if got := hostAliases(test.servers, test.prefix); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRenderSSHConfig(t *testing.T) {
+	dir := t.TempDir()
+	viper.SetConfigFile(filepath.Join(dir, "config.yml"))
+	defer viper.SetConfigFile("")
+
+	knownHosts := filepath.Join(dir, ".tensordock", "known_hosts")
+	servers := map[string]api.Server{
+		"s2": {Id: "s2", Name: "train", Ip: "10.0.0.2"},
+		"s1": {Id: "s1", Name: "eval", Ip: "10.0.0.1"},
+		"s3": {Id: "s3", Name: "pending"},
+	}
+
+	tests := []struct {
+		name    string
+		options sshConfigOptions
+		want    string
+	}{
+		{"defaults", sshConfigOptions{User: "user", Port: 22}, `# eval (s1)
+Host eval
+    HostName 10.0.0.1
+    User user
+    HostKeyAlias s1
+    UserKnownHostsFile KNOWN_HOSTS
+    StrictHostKeyChecking accept-new
+
+# train (s2)
+Host train
+    HostName 10.0.0.2
+    User user
+    HostKeyAlias s2
+    UserKnownHostsFile KNOWN_HOSTS
+    StrictHostKeyChecking accept-new
+`},
+		{"port and identity", sshConfigOptions{Prefix: "td-", User: "root", Port: 2222, Identity: "/keys/id"}, `# eval (s1)
+Host td-eval
+    HostName 10.0.0.1
+    User root
+    Port 2222
+    IdentityFile /keys/id
+    HostKeyAlias s1
+    UserKnownHostsFile KNOWN_HOSTS
+    StrictHostKeyChecking accept-new
+
+# train (s2)
+Host td-train
+    HostName 10.0.0.2
+    User root
+    Port 2222
+    IdentityFile /keys/id
+    HostKeyAlias s2
+    UserKnownHostsFile KNOWN_HOSTS
+    StrictHostKeyChecking accept-new
+`},
+	}
+
+	for _, test := range tests {
+		got, err := renderSSHConfig(servers, test.options)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		want := strings.ReplaceAll(test.want, "KNOWN_HOSTS", knownHosts)
+		if string(got) != want {
+			t.Errorf("%v: got\n%v\nwant\n%v", test.name, string(got), want)
+		}
+	}
+}
+
+func TestSSHConfigStale(t *testing.T) {
+	servers := map[string]api.Server{
+		"s1": {Id: "s1", Ip: "10.0.0.1"},
+		"s2": {Id: "s2", Ip: "10.0.0.2"},
+		"s3": {Id: "s3"},
+	}
+
+	tests := []struct {
+		name    string
+		managed map[string]string
+		want    bool
+	}{
+		{"current", map[string]string{"s1": "10.0.0.1", "s2": "10.0.0.2"}, false},
+		{"new address", map[string]string{"s1": "10.0.0.1", "s2": "10.0.0.9"}, true},
+		{"server added", map[string]string{"s1": "10.0.0.1"}, true},
+		{"server removed", map[string]string{"s1": "10.0.0.1", "s2": "10.0.0.2", "s4": "10.0.0.4"}, true},
+		{"empty file", map[string]string{}, true},
+	}
+
+	for _, test := range tests {
+		if got := sshConfigStale(test.managed, servers); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestManagedAddresses(t *testing.T) {
+	config := []byte(sshConfigHeader + `
+# eval (s1)
+Host eval
+    HostName 10.0.0.1
+    User user
+    HostKeyAlias s1
+
+# train (s2)
+Host train
+    HostName 10.0.0.2
+    HostKeyAlias s2
+`)
+
+	want := map[string]string{"s1": "10.0.0.1", "s2": "10.0.0.2"}
+	if got := managedAddresses(config); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
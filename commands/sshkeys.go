@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
+)
+
+const installKeysScript = `umask 077
+mkdir -p ~/.ssh
+touch ~/.ssh/authorized_keys
+while IFS= read -r key; do
+	grep -qxF "$key" ~/.ssh/authorized_keys || printf '%s\n' "$key" >> ~/.ssh/authorized_keys
+done`
+
+// disablePasswordAuthScript turns off password logins with a drop-in that
+// sorts before others such as Ubuntu's 50-cloud-init.conf, since sshd uses
+// the first value it reads. sshd_config itself is edited too for images that
+// don't include sshd_config.d. The effective setting is checked with sshd -T
+// before reloading.
+const disablePasswordAuthScript = `sudo -S -p '' sh -c '
+set -e
+mkdir -p /etc/ssh/sshd_config.d
+echo "PasswordAuthentication no" > /etc/ssh/sshd_config.d/00-tensordock-cli.conf
+chmod 644 /etc/ssh/sshd_config.d/00-tensordock-cli.conf
+sed -i -E "s/^#?PasswordAuthentication .*/PasswordAuthentication no/" /etc/ssh/sshd_config
+if ! sshd -T | grep -qix "passwordauthentication no"; then
+	echo "sshd still allows password authentication" >&2
+	exit 1
+fi
+systemctl reload sshd || systemctl reload ssh
+'`
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// readPublicKeys loads and checks the given public key files, defaulting to
+// the "sshKeys" list in the config file when none are given.
+func readPublicKeys(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = viper.GetStringSlice("sshKeys")
+	}
+
+	keys := []string{}
+	for _, path := range paths {
+		bytes, err := os.ReadFile(expandHome(path))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(string(bytes), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line)); err != nil {
+				return nil, fmt.Errorf("%v is not a valid public key file: %v", path, err)
+			}
+
+			keys = append(keys, line)
+		}
+	}
+
+	return keys, nil
+}
+
+func runSession(conn *ssh.Client, command string, stdin string) error {
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = strings.NewReader(stdin)
+	out, err := session.CombinedOutput(command)
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %v", err, msg)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// installPublicKeys logs in with the admin credentials and appends the keys
+// to the admin user's authorized_keys.
+func installPublicKeys(flags *pflag.FlagSet, id string, user string, password string, keys []string, timeout time.Duration) error {
+	if len(keys) == 0 {
+		return errors.New("no public keys to install")
+	}
+
+	log.Printf("waiting for %v to become reachable", id)
+
+	target, err := waitForSSH(flags, id, timeout)
+	if err != nil {
+		return err
+	}
+
+	target.User = user
+	target.Password = password
+
+	conn, err := target.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := runSession(conn, installKeysScript, strings.Join(keys, "\n")+"\n"); err != nil {
+		return fmt.Errorf("failed to install keys: %v", err)
+	}
+
+	log.Printf("installed %v key(s) for %v", len(keys), user)
+
+	return nil
+}
+
+// disablePasswordLogins turns off password logins, but only after logging
+// in with a key over a fresh connection succeeds so that a key the server
+// didn't accept can't lock the user out. The password is still needed for
+// sudo.
+func disablePasswordLogins(flags *pflag.FlagSet, id string, user string, password string, timeout time.Duration) error {
+	target, err := waitForSSH(flags, id, timeout)
+	if err != nil {
+		return err
+	}
+
+	target.User = user
+
+	conn, err := target.dial()
+	if err != nil {
+		return fmt.Errorf("logging in with a key failed, leaving password authentication enabled: %v", err)
+	}
+	defer conn.Close()
+
+	if err := runSession(conn, disablePasswordAuthScript, password+"\n"); err != nil {
+		return fmt.Errorf("failed to disable password authentication: %v", err)
+	}
+
+	log.Print("disabled password authentication")
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// stateDir returns the directory used for local state files, creating it if
+// needed. It lives next to the config file.
+func stateDir() (string, error) {
+	dir := filepath.Join(filepath.Dir(viper.ConfigFileUsed()), ".tensordock")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func readState(name string, v interface{}) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	bytes, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bytes, v)
+}
+
+func writeState(name string, v interface{}) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
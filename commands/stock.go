@@ -1,8 +1,19 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
@@ -18,76 +29,560 @@ var (
 		Short: "List stock",
 		RunE:  listStock,
 	}
+	watchStockCmd = &cobra.Command{
+		Use:   "watch",
+		Short: "Wait for stock to become available",
+		Long: `Polls stock and prints changes as they happen.
+
+Exits once a model/location pair matching --gpu/--cpu and --location has at
+least --min units available now, optionally notifying a webhook first.`,
+		RunE: watchStock,
+	}
 )
 
+type stockEntry struct {
+	Model            string `json:"model"`
+	Location         string `json:"location"`
+	AvailableNow     int    `json:"available_now"`
+	AvailableReserve int    `json:"available_reserve"`
+}
+
 func init() {
 	stockCmd.AddCommand(listStockCmd)
 	listStockCmd.Flags().String("type", "gpu", "Instance type (gpu or cpu)")
 	listStockCmd.Flags().Bool("all", false, "Include out-of-stock instances")
+	listStockCmd.Flags().String("gpu", "", "Only show GPU models matching this pattern (implies --type gpu)")
+	listStockCmd.Flags().String("cpu", "", "Only show CPU models matching this pattern (implies --type cpu)")
+	listStockCmd.Flags().String("location", "", "Only show locations matching this wildcard pattern or /regex/")
+	listStockCmd.Flags().Int("minAvailable", 0, "Only show entries with at least this many units available now")
+	listStockCmd.Flags().String("sort", "model", "Sort by model, location, available or reserve")
+	listStockCmd.Flags().String("groupBy", "", "Summarize totals by model or region")
+	addWatchFlags(listStockCmd.Flags())
+
+	stockCmd.AddCommand(watchStockCmd)
+	watchStockCmd.Flags().String("gpu", "", "GPU model to watch for, wildcards allowed")
+	watchStockCmd.Flags().String("cpu", "", "CPU model to watch for, wildcards allowed")
+	watchStockCmd.Flags().String("location", "*", "Location to watch, wildcards or /regex/ allowed (e.g. na-us-*)")
+	watchStockCmd.Flags().Int("min", 1, "Minimum number of units available now")
+	watchStockCmd.Flags().Duration("interval", time.Minute, "Time between polls")
+	watchStockCmd.Flags().String("webhook", "", "URL to POST the matching stock to when the condition is met")
+	watchStockCmd.Flags().Bool("keepWatching", false, "Keep watching after the condition is met")
 
 	rootCmd.AddCommand(stockCmd)
 }
 
 func listStock(cmd *cobra.Command, args []string) error {
-	instanceType, err := cmd.Flags().GetString("type")
+	flags := cmd.Flags()
+
+	instanceType, err := flags.GetString("type")
+	if err != nil {
+		return err
+	}
+
+	all, err := flags.GetBool("all")
+	if err != nil {
+		return err
+	}
+
+	gpu, err := flags.GetString("gpu")
 	if err != nil {
 		return err
 	}
 
-	all, err := cmd.Flags().GetBool("all")
+	cpu, err := flags.GetString("cpu")
 	if err != nil {
 		return err
 	}
 
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
+	location, err := flags.GetString("location")
+	if err != nil {
+		return err
+	}
+
+	minAvailable, err := flags.GetInt("minAvailable")
+	if err != nil {
+		return err
+	}
+
+	sortBy, err := flags.GetString("sort")
+	if err != nil {
+		return err
+	}
+
+	groupBy, err := flags.GetString("groupBy")
+	if err != nil {
+		return err
+	}
+
+	model := ""
+	switch {
+	case gpu != "" && cpu != "":
+		return errors.New("only one of --gpu or --cpu may be set")
+	case gpu != "" && flags.Changed("type") && instanceType != "gpu":
+		return fmt.Errorf("--gpu can't be used with --type %v", instanceType)
+	case cpu != "" && flags.Changed("type") && instanceType != "cpu":
+		return fmt.Errorf("--cpu can't be used with --type %v", instanceType)
+	case gpu != "":
+		instanceType, model = "gpu", gpu
+	case cpu != "":
+		instanceType, model = "cpu", cpu
+	}
+
+	fetch := func() ([]stockEntry, error) {
+		entries, err := fetchStock(instanceType)
+		if err != nil {
+			return nil, err
+		}
+
+		filtered := []stockEntry{}
+		for _, entry := range entries {
+			if !all && entry.AvailableNow == 0 && entry.AvailableReserve == 0 {
+				continue
+			}
+
+			if entry.AvailableNow < minAvailable {
+				continue
+			}
+
+			modelOk, err := matchPattern(model, entry.Model)
+			if err != nil {
+				return nil, err
+			}
+
+			locationOk, err := matchPattern(location, entry.Location)
+			if err != nil {
+				return nil, err
+			}
+
+			if modelOk && locationOk {
+				filtered = append(filtered, entry)
+			}
+		}
+
+		if groupBy != "" {
+			filtered, err = groupStock(filtered, groupBy)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if err := sortStock(filtered, sortBy); err != nil {
+			return nil, err
+		}
+
+		return filtered, nil
+	}
+
+	// grouped rows only carry a count in the non-grouped column,
+	// so sort by the grouping key instead
+	if groupBy == "region" && sortBy == "model" {
+		sortBy = "location"
+	} else if groupBy == "model" && (sortBy == "location" || sortBy == "region") {
+		sortBy = "model"
+	}
+
+	modelHeader := "GPU"
+	if instanceType == "cpu" {
+		modelHeader = "CPU Model"
+	}
+
+	var header table.Row
+	switch groupBy {
+	case "model":
+		header = table.Row{modelHeader, "Regions", "Available Now", "Available Reserve"}
+	case "region":
+		header = table.Row{"Region", "Models", "Available Now", "Available Reserve"}
+	default:
+		header = table.Row{modelHeader, "VRAM", "Region", "Location", "Available Now", "Available Reserve"}
+	}
+
+	render := func(filtered []stockEntry) error {
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(stockColumns(header, instanceType, groupBy))
+
+		totalNow, totalReserve := 0, 0
+		for _, entry := range filtered {
+			var row table.Row
+			switch groupBy {
+			case "model":
+				row = table.Row{entry.Model, entry.Location, entry.AvailableNow, entry.AvailableReserve}
+			case "region":
+				row = table.Row{entry.Location, entry.Model, entry.AvailableNow, entry.AvailableReserve}
+			default:
+				vram := ""
+				if size, ok := gpuVRAM(entry.Model); ok {
+					vram = fmt.Sprintf("%vGB", size)
+				}
+				row = table.Row{entry.Model, vram, entry.Location, locationLabel(entry.Location), entry.AvailableNow, entry.AvailableReserve}
+			}
+			t.AppendRow(stockColumns(row, instanceType, groupBy))
+
+			totalNow += entry.AvailableNow
+			totalReserve += entry.AvailableReserve
+		}
+
+		if groupBy != "" {
+			footer := table.Row{"Total", "", totalNow, totalReserve}
+			t.AppendFooter(stockColumns(footer, instanceType, groupBy))
+		}
+
+		t.Render()
+
+		return nil
+	}
+
+	return watchSpec[stockEntry]{
+		fetch:  fetch,
+		render: render,
+		key: func(entry stockEntry) string {
+			switch groupBy {
+			case "model":
+				return entry.Model
+			case "region":
+				return entry.Location
+			}
+			return entry.Model + "@" + entry.Location
+		},
+		change: func(before stockEntry, after stockEntry) (string, interface{}) {
+			return "changed", map[string]int{
+				"available_now":     after.AvailableNow - before.AvailableNow,
+				"available_reserve": after.AvailableReserve - before.AvailableReserve,
+			}
+		},
+	}.run(flags)
+}
+
+// stockColumns drops the GPU-only columns (VRAM and reserve) for CPU stock.
+func stockColumns(row table.Row, instanceType string, groupBy string) table.Row {
+	if instanceType != "cpu" {
+		return row
+	}
+
+	if groupBy == "" {
+		return table.Row{row[0], row[2], row[3], row[4]}
+	}
+
+	return row[:3]
+}
+
+// groupStock sums stock per model or per region. The other column of the
+// result holds the number of distinct entries that were summed.
+func groupStock(entries []stockEntry, groupBy string) ([]stockEntry, error) {
+	groups := map[string]*stockEntry{}
+	counts := map[string]int{}
+	order := []string{}
+
+	for _, entry := range entries {
+		var key string
+		switch groupBy {
+		case "model":
+			key = entry.Model
+		case "region":
+			key = entry.Location
+		default:
+			return nil, errors.New("unknown grouping, expected model or region")
+		}
+
+		group, ok := groups[key]
+		if !ok {
+			group = &stockEntry{Model: key, Location: key}
+			groups[key] = group
+			order = append(order, key)
+		}
+
+		group.AvailableNow += entry.AvailableNow
+		group.AvailableReserve += entry.AvailableReserve
+		counts[key]++
+	}
+
+	grouped := []stockEntry{}
+	for _, key := range order {
+		group := *groups[key]
+		if groupBy == "model" {
+			group.Location = strconv.Itoa(counts[key])
+		} else {
+			group.Model = strconv.Itoa(counts[key])
+		}
+		grouped = append(grouped, group)
+	}
+
+	return grouped, nil
+}
+
+// sortStock orders entries by the given key, falling back to model and
+// location so output is deterministic. Availability sorts descending.
+func sortStock(entries []stockEntry, sortBy string) error {
+	var less func(a, b stockEntry) bool
+	switch sortBy {
+	case "model":
+		less = func(a, b stockEntry) bool { return a.Model < b.Model }
+	case "location", "region":
+		less = func(a, b stockEntry) bool { return a.Location < b.Location }
+	case "available":
+		less = func(a, b stockEntry) bool { return a.AvailableNow > b.AvailableNow }
+	case "reserve":
+		less = func(a, b stockEntry) bool { return a.AvailableReserve > b.AvailableReserve }
+	default:
+		return errors.New("unknown sort key, expected model, location, available or reserve")
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if less(entries[i], entries[j]) {
+			return true
+		}
+		if less(entries[j], entries[i]) {
+			return false
+		}
+		if entries[i].Model != entries[j].Model {
+			return entries[i].Model < entries[j].Model
+		}
+		return entries[i].Location < entries[j].Location
+	})
+
+	return nil
+}
+
+func fetchStock(instanceType string) ([]stockEntry, error) {
+	entries := []stockEntry{}
 
 	switch instanceType {
 	case "gpu":
 		res, err := client.ListGpuStock()
 		if err != nil {
-			return nil
+			return nil, err
 		}
 
 		if !res.Success {
-			return errors.New(res.Error)
+			return nil, errors.New(res.Error)
 		}
 
-		t.AppendHeader(table.Row{"GPU", "Region", "Available Now", "Available Reserve"})
-
-		for gpuModel, regionStock := range res.Stock {
+		for model, regionStock := range res.Stock {
 			for region, stock := range regionStock {
-				if (stock.AvailableNow > 0 || stock.AvailableReserve > 0) || all {
-					t.AppendRow(table.Row{gpuModel, region, stock.AvailableNow, stock.AvailableReserve})
-				}
+				entries = append(entries, stockEntry{model, region, stock.AvailableNow, stock.AvailableReserve})
 			}
 		}
 
 	case "cpu":
 		res, err := client.ListCpuStock()
 		if err != nil {
-			return nil
+			return nil, err
 		}
 
 		if !res.Success {
-			return errors.New(res.Error)
+			return nil, errors.New(res.Error)
 		}
 
-		t.AppendHeader(table.Row{"CPU Model", "Region", "Available Now"})
-
-		for cpuModel, regionStock := range res.Stock {
+		for model, regionStock := range res.Stock {
 			for region, stock := range regionStock {
-				if stock.AvailableNow != "None" || all {
-					t.AppendRow(table.Row{cpuModel, region, stock.AvailableNow})
-				}
+				entries = append(entries, stockEntry{model, region, parseCpuStock(stock.AvailableNow), 0})
 			}
 		}
 
 	default:
-		return errors.New("unknown instance type")
+		return nil, errors.New("unknown instance type")
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Model != entries[j].Model {
+			return entries[i].Model < entries[j].Model
+		}
+		return entries[i].Location < entries[j].Location
+	})
+
+	return entries, nil
+}
+
+// parseCpuStock converts the CPU stock endpoint's string counts, which use
+// "None" for zero, into numbers.
+func parseCpuStock(value string) int {
+	count, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// matchPattern matches a value against a wildcard pattern, or a regular
+// expression when the pattern is wrapped in slashes (/^na-us/). Patterns
+// without wildcards match anywhere in the value, so "a100" matches
+// "A100_80GB". All forms are case-insensitive and an empty pattern matches
+// everything.
+func matchPattern(pattern string, value string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(value), nil
+	}
+
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(pattern)), nil
+	}
+
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	if err != nil {
+		return false, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	return ok, nil
+}
+
+// newlyAvailable reports whether an entry has at least min units available
+// now but didn't on the previous poll, or wasn't listed then. Only these are
+// reported so that --keepWatching doesn't notify on every poll.
+func newlyAvailable(entry stockEntry, previous stockEntry, listed bool, min int) bool {
+	return entry.AvailableNow >= min && (!listed || previous.AvailableNow < min)
+}
+
+func watchStock(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	gpu, err := flags.GetString("gpu")
+	if err != nil {
+		return err
+	}
+
+	cpu, err := flags.GetString("cpu")
+	if err != nil {
+		return err
+	}
+
+	location, err := flags.GetString("location")
+	if err != nil {
+		return err
+	}
+
+	min, err := flags.GetInt("min")
+	if err != nil {
+		return err
+	}
+
+	interval, err := flags.GetDuration("interval")
+	if err != nil {
+		return err
+	}
+
+	webhook, err := flags.GetString("webhook")
+	if err != nil {
+		return err
+	}
+
+	keepWatching, err := flags.GetBool("keepWatching")
+	if err != nil {
+		return err
+	}
+
+	instanceType, model := "gpu", gpu
+	switch {
+	case gpu != "" && cpu != "":
+		return errors.New("only one of --gpu or --cpu may be set")
+	case cpu != "":
+		instanceType, model = "cpu", cpu
+	}
+
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+
+	previous := map[string]stockEntry{}
+	first := true
+	for {
+		entries, err := fetchStock(instanceType)
+		if err != nil {
+			log.Printf("warning: %v", err)
+			time.Sleep(interval)
+			continue
+		}
+
+		current := map[string]stockEntry{}
+		matched := []stockEntry{}
+		for _, entry := range entries {
+			modelOk, err := matchPattern(model, entry.Model)
+			if err != nil {
+				return err
+			}
+
+			locationOk, err := matchPattern(location, entry.Location)
+			if err != nil {
+				return err
+			}
+
+			if !modelOk || !locationOk {
+				continue
+			}
+
+			key := entry.Model + "/" + entry.Location
+			current[key] = entry
+
+			old, ok := previous[key]
+			if first || !ok || old != entry {
+				fmt.Printf("%v %v %v: now %v -> %v, reserve %v -> %v\n",
+					time.Now().Format("15:04:05"),
+					entry.Model,
+					entry.Location,
+					old.AvailableNow,
+					entry.AvailableNow,
+					old.AvailableReserve,
+					entry.AvailableReserve)
+			}
+
+			if newlyAvailable(entry, old, ok, min) {
+				matched = append(matched, entry)
+			}
+		}
+
+		for key, old := range previous {
+			if _, ok := current[key]; !ok {
+				fmt.Printf("%v %v %v: no longer listed\n", time.Now().Format("15:04:05"), old.Model, old.Location)
+			}
+		}
+
+		if len(matched) > 0 {
+			for _, entry := range matched {
+				log.Printf("available: %v x%v in %v", entry.Model, entry.AvailableNow, entry.Location)
+			}
+
+			if webhook != "" {
+				if err := postWebhook(webhook, matched); err != nil {
+					log.Printf("warning: webhook failed: %v", err)
+				}
+			}
+
+			if !keepWatching {
+				return nil
+			}
+		}
+
+		previous = current
+		first = false
+		time.Sleep(interval)
+	}
+}
+
+// webhookClient keeps a slow or unreachable webhook from stalling stock
+// watch and audited commands.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func postWebhook(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	res, err := webhookClient.Post(url, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return err
 	}
+	defer res.Body.Close()
 
-	t.Render()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %v", res.Status)
+	}
 
 	return nil
 }
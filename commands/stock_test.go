@@ -0,0 +1,89 @@
+package commands
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"", "A4000", true, false},
+		{"A4000", "A4000", true, false},
+		{"a100", "A100_80GB", true, false},
+		{"80gb", "A100_80GB", true, false},
+		{"A5000", "A4000", false, false},
+		{"na-us-*", "na-us-chi-1", true, false},
+		{"na-us-*", "eu-de-1", false, false},
+		{"*-chi-?", "na-us-chi-1", true, false},
+		{"A100", "a100", true, false},
+		{"/^na-us/", "na-us-nyc-1", true, false},
+		{"/^na-us/", "eu-na-us-1", false, false},
+		{"/^NA-US/", "na-us-nyc-1", true, false},
+		{"/a100_80gb$/", "A100_80GB", true, false},
+		{"/(?i)a4000/", "A4000", true, false},
+		{"/[/", "anything", false, true},
+		{"[", "anything", false, true},
+	}
+
+	for _, test := range tests {
+		got, err := matchPattern(test.pattern, test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("matchPattern(%q, %q) error = %v, want error %v", test.pattern, test.value, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", test.pattern, test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseCpuStock(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"12", 12},
+		{" 3 ", 3},
+		{"0", 0},
+		{"None", 0},
+		{"", 0},
+	}
+
+	for _, test := range tests {
+		if got := parseCpuStock(test.value); got != test.want {
+			t.Errorf("parseCpuStock(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestNewlyAvailable(t *testing.T) {
+	entry := func(now int) stockEntry {
+		return stockEntry{Model: "A4000", Location: "na-us-chi-1", AvailableNow: now}
+	}
+
+	tests := []struct {
+		name     string
+		entry    stockEntry
+		previous stockEntry
+		listed   bool
+		min      int
+		want     bool
+	}{
+		{"first seen with stock", entry(2), stockEntry{}, false, 1, true},
+		{"first seen without stock", entry(0), stockEntry{}, false, 1, false},
+		{"stock arrived", entry(3), entry(0), true, 1, true},
+		{"still available", entry(3), entry(2), true, 1, false},
+		{"below min", entry(1), entry(0), true, 2, false},
+		{"reached min", entry(2), entry(1), true, 2, true},
+		{"stock dropped", entry(0), entry(4), true, 1, false},
+		{"min of zero", entry(0), stockEntry{}, false, 0, true},
+	}
+
+	for _, test := range tests {
+		if got := newlyAvailable(test.entry, test.previous, test.listed, test.min); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
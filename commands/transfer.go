@@ -0,0 +1,634 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+var (
+	cpCmd = &cobra.Command{
+		Use:   "cp [flags] source destination",
+		Short: "Copy a file to or from a server",
+		Long: `Copies a single file between this machine and a server. Exactly one of the
+paths must be remote, written as server_id:path (relative paths are resolved
+from the remote user's home directory).
+
+With --resume, an existing shorter destination file is treated as a partial
+copy if its contents match the start of the source (compared by SHA-256), and
+only the remaining bytes are transferred. Otherwise the file is copied again
+from the beginning.
+
+The remote side uses GNU coreutils (stat, touch, sha256sum), so the server must
+run GNU/Linux, as all TensorDock images do.`,
+		Example: `  tensordock-cli servers cp dataset.tar.gz server_id:data/
+  tensordock-cli servers cp server_id:checkpoints/model.pt .`,
+		Args: cobra.ExactArgs(2),
+		RunE: copyFile,
+	}
+	syncCmd = &cobra.Command{
+		Use:   "sync [flags] source destination",
+		Short: "Synchronize a directory to or from a server",
+		Long: `Copies files that are missing or differ in size or modification time from the
+source directory to the destination directory. Exactly one of the paths must
+be remote, written as server_id:path.
+
+The remote side uses GNU find and coreutils, so the server must run
+GNU/Linux, as all TensorDock images do.`,
+		Example: `  tensordock-cli servers sync ./dataset server_id:dataset
+  tensordock-cli servers sync --delete server_id:runs ./runs`,
+		Args: cobra.ExactArgs(2),
+		RunE: syncDirectory,
+	}
+)
+
+type remotePath struct {
+	ID   string
+	Path string
+}
+
+type fileInfo struct {
+	Size    int64
+	ModTime int64
+}
+
+func init() {
+	cpCmd.Flags().Bool("resume", false, "Resume a partial transfer")
+	addSSHFlags(cpCmd.Flags())
+	serversCmd.AddCommand(cpCmd)
+
+	syncCmd.Flags().Bool("delete", false, "Delete destination files that don't exist in the source")
+	addSSHFlags(syncCmd.Flags())
+	serversCmd.AddCommand(syncCmd)
+}
+
+// parseRemotePath splits "server_id:path". Single letters before the colon
+// are treated as Windows drive letters rather than server IDs.
+func parseRemotePath(value string) (*remotePath, bool) {
+	i := strings.Index(value, ":")
+	if i <= 1 || strings.ContainsAny(value[:i], `/\`) {
+		return nil, false
+	}
+	return &remotePath{value[:i], value[i+1:]}, true
+}
+
+// parseTransferArgs returns the local and remote sides of a transfer and
+// whether it is an upload.
+func parseTransferArgs(src string, dst string) (string, *remotePath, bool, error) {
+	srcRemote, srcIsRemote := parseRemotePath(src)
+	dstRemote, dstIsRemote := parseRemotePath(dst)
+
+	switch {
+	case srcIsRemote && dstIsRemote:
+		return "", nil, false, errors.New("copying between two servers is not supported")
+	case dstIsRemote:
+		return src, dstRemote, true, nil
+	case srcIsRemote:
+		return dst, srcRemote, false, nil
+	default:
+		return "", nil, false, errors.New("one of the paths must be remote (server_id:path)")
+	}
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// remoteShellPath quotes a remote path while keeping a leading ~/ expandable.
+func remoteShellPath(value string) string {
+	if value == "" || value == "~" {
+		return "."
+	}
+	if strings.HasPrefix(value, "~/") {
+		return "~/" + shellQuote(value[2:])
+	}
+	return shellQuote(value)
+}
+
+func remoteOutput(conn *ssh.Client, command string) (string, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	out, err := session.Output(command)
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %v", err, msg)
+		}
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// remoteStat returns the size of a remote file and whether it is a
+// directory, or nil if it doesn't exist.
+func remoteStat(conn *ssh.Client, remote string) (*fileInfo, bool, error) {
+	out, err := remoteOutput(conn, fmt.Sprintf("if [ -d %[1]v ]; then echo dir; elif [ -e %[1]v ]; then stat -c '%%s %%Y' -- %[1]v; else echo none; fi", remoteShellPath(remote)))
+	if err != nil {
+		return nil, false, err
+	}
+
+	fields := strings.Fields(out)
+	switch {
+	case len(fields) == 1 && fields[0] == "dir":
+		return nil, true, nil
+	case len(fields) == 1 && fields[0] == "none":
+		return nil, false, nil
+	case len(fields) == 2:
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, false, err
+		}
+		mtime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, false, err
+		}
+		return &fileInfo{size, mtime}, false, nil
+	}
+
+	return nil, false, fmt.Errorf("unexpected output from stat: %q", out)
+}
+
+// localPrefixSum returns the hex SHA-256 of the first n bytes of a local file.
+func localPrefixSum(local string, n int64) (string, error) {
+	file, err := os.Open(local)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	copied, err := io.CopyN(hash, file, n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if copied != n {
+		return "", fmt.Errorf("%v is shorter than %v bytes", local, n)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// remotePrefixSum returns the hex SHA-256 of the first n bytes of a remote
+// file.
+func remotePrefixSum(conn *ssh.Client, remote string, n int64) (string, error) {
+	out, err := remoteOutput(conn, fmt.Sprintf("head -c %v %v | sha256sum", n, remoteShellPath(remote)))
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected output from sha256sum: %q", out)
+	}
+
+	return fields[0], nil
+}
+
+// partialMatches reports whether the first n bytes of the local and remote
+// files are identical, so a resumed transfer can append to the shorter one.
+func partialMatches(conn *ssh.Client, local string, remote string, n int64) (bool, error) {
+	localSum, err := localPrefixSum(local, n)
+	if err != nil {
+		return false, err
+	}
+
+	remoteSum, err := remotePrefixSum(conn, remote, n)
+	if err != nil {
+		return false, err
+	}
+
+	return localSum == remoteSum, nil
+}
+
+// progressWriter counts bytes written and redraws a progress line on a
+// terminal at most a few times per second.
+type progressWriter struct {
+	label string
+	total int64
+	done  int64
+	start time.Time
+	last  time.Time
+	tty   bool
+}
+
+func newProgressWriter(label string, offset int64, total int64) *progressWriter {
+	return &progressWriter{
+		label: label,
+		total: total,
+		done:  offset,
+		start: time.Now(),
+		tty:   term.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	if w.tty && time.Since(w.last) > 200*time.Millisecond {
+		w.draw()
+		w.last = time.Now()
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) draw() {
+	percent := 100.0
+	if w.total > 0 {
+		percent = float64(w.done) / float64(w.total) * 100
+	}
+
+	rate := float64(w.done) / time.Since(w.start).Seconds()
+	fmt.Fprintf(os.Stderr, "\r%v %5.1f%% %v/%v %v/s\033[K", w.label, percent, formatBytes(float64(w.done)), formatBytes(float64(w.total)), formatBytes(rate))
+}
+
+func (w *progressWriter) Finish() {
+	if w.tty {
+		w.draw()
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func formatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%v", n, units[i])
+}
+
+func upload(conn *ssh.Client, local string, remote string, resume bool) error {
+	file, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	info, isDir, err := remoteStat(conn, remote)
+	if err != nil {
+		return err
+	}
+
+	if isDir || strings.HasSuffix(remote, "/") {
+		remote = path.Join(remote, filepath.Base(local))
+		if info, _, err = remoteStat(conn, remote); err != nil {
+			return err
+		}
+	}
+
+	var offset int64
+	redirect := ">"
+	if resume && info != nil && info.Size <= stat.Size() {
+		matches, err := partialMatches(conn, local, remote, info.Size)
+		if err != nil {
+			return err
+		}
+
+		if matches {
+			offset = info.Size
+			redirect = ">>"
+		} else {
+			log.Printf("warning: %v doesn't match the start of %v, copying it again", remote, local)
+		}
+	}
+
+	if offset == stat.Size() && offset > 0 {
+		log.Printf("%v is already complete", remote)
+		return nil
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	progress := newProgressWriter(filepath.Base(local), offset, stat.Size())
+	session.Stdin = io.TeeReader(file, progress)
+
+	quoted := remoteShellPath(remote)
+	err = session.Run(fmt.Sprintf("mkdir -p \"$(dirname %[1]v)\" && cat %[2]v %[1]v && touch -d @%[3]v %[1]v", quoted, redirect, stat.ModTime().Unix()))
+	progress.Finish()
+
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%v: %v", err, msg)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func download(conn *ssh.Client, remote string, local string, resume bool) error {
+	info, isDir, err := remoteStat(conn, remote)
+	if err != nil {
+		return err
+	}
+
+	if isDir {
+		return fmt.Errorf("%v is a directory, use servers sync instead", remote)
+	}
+
+	if info == nil {
+		return fmt.Errorf("%v does not exist", remote)
+	}
+
+	if stat, err := os.Stat(local); err == nil && stat.IsDir() {
+		local = filepath.Join(local, path.Base(remote))
+	}
+
+	var offset int64
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if stat, err := os.Stat(local); err == nil && stat.Size() <= info.Size {
+			matches, err := partialMatches(conn, local, remote, stat.Size())
+			if err != nil {
+				return err
+			}
+
+			if matches {
+				offset = stat.Size()
+				flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+			} else {
+				log.Printf("warning: %v doesn't match the start of %v, copying it again", local, remote)
+			}
+		}
+	}
+
+	if offset == info.Size && offset > 0 {
+		log.Printf("%v is already complete", local)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(local, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	progress := newProgressWriter(path.Base(remote), offset, info.Size)
+	session.Stdout = io.MultiWriter(file, progress)
+
+	err = session.Run(fmt.Sprintf("tail -c +%v %v", offset+1, remoteShellPath(remote)))
+	progress.Finish()
+
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%v: %v", err, msg)
+		}
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	mtime := time.Unix(info.ModTime, 0)
+	return os.Chtimes(local, mtime, mtime)
+}
+
+func copyFile(cmd *cobra.Command, args []string) error {
+	local, remote, isUpload, err := parseTransferArgs(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	resume, err := cmd.Flags().GetBool("resume")
+	if err != nil {
+		return err
+	}
+
+	target, err := sshTargetFor(cmd.Flags(), remote.ID)
+	if err != nil {
+		return err
+	}
+
+	conn, err := target.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if isUpload {
+		return upload(conn, local, remote.Path, resume)
+	}
+
+	return download(conn, remote.Path, local, resume)
+}
+
+func listLocalFiles(dir string) (map[string]fileInfo, error) {
+	files := map[string]fileInfo{}
+
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)] = fileInfo{info.Size(), info.ModTime().Unix()}
+		return nil
+	})
+
+	return files, err
+}
+
+func listRemoteFiles(conn *ssh.Client, dir string) (map[string]fileInfo, error) {
+	out, err := remoteOutput(conn, fmt.Sprintf("cd %v 2>/dev/null || exit 0; find . -type f -printf '%%P\\t%%s\\t%%T@\\n'", remoteShellPath(dir)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]fileInfo{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		mtime, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		files[fields[0]] = fileInfo{size, int64(mtime)}
+	}
+
+	return files, nil
+}
+
+func syncDirectory(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	local, remote, isUpload, err := parseTransferArgs(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	deleteExtra, err := flags.GetBool("delete")
+	if err != nil {
+		return err
+	}
+
+	target, err := sshTargetFor(flags, remote.ID)
+	if err != nil {
+		return err
+	}
+
+	conn, err := target.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	localFiles, err := listLocalFiles(local)
+	if err != nil {
+		return err
+	}
+
+	remoteFiles, err := listRemoteFiles(conn, remote.Path)
+	if err != nil {
+		return err
+	}
+
+	source, dest := localFiles, remoteFiles
+	if !isUpload {
+		source, dest = remoteFiles, localFiles
+	}
+
+	names := sortedKeys(source)
+	transferred, deleted := 0, 0
+	for _, name := range names {
+		if existing, ok := dest[name]; ok && existing == source[name] {
+			continue
+		}
+
+		localPath := filepath.Join(local, filepath.FromSlash(name))
+		remoteFile := path.Join(remote.Path, name)
+
+		if client.DryRun {
+			fmt.Printf("would copy %v\n", name)
+			transferred++
+			continue
+		}
+
+		if isUpload {
+			err = upload(conn, localPath, remoteFile, false)
+		} else {
+			err = download(conn, remoteFile, localPath, false)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		transferred++
+	}
+
+	if deleteExtra {
+		extra := []string{}
+		for name := range dest {
+			if _, ok := source[name]; !ok {
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+
+		for _, name := range extra {
+			if client.DryRun {
+				fmt.Printf("would delete %v\n", name)
+				deleted++
+				continue
+			}
+
+			if isUpload {
+				_, err = remoteOutput(conn, "rm -f -- "+remoteShellPath(path.Join(remote.Path, name)))
+			} else {
+				err = os.Remove(filepath.Join(local, filepath.FromSlash(name)))
+			}
+
+			if err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+
+			deleted++
+		}
+	}
+
+	if client.DryRun {
+		return nil
+	}
+
+	log.Printf("%v file(s) copied, %v deleted, %v unchanged", transferred, deleted, len(source)-transferred)
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTransferArgs(t *testing.T) {
+	tests := []struct {
+		src      string
+		dst      string
+		local    string
+		remote   remotePath
+		isUpload bool
+		wantErr  bool
+	}{
+		{"data.tar", "s1:data/", "data.tar", remotePath{"s1", "data/"}, true, false},
+		{"s1:model.pt", ".", ".", remotePath{"s1", "model.pt"}, false, false},
+		{"s1:", "out", "out", remotePath{"s1", ""}, false, false},
+		{`C:\data.tar`, "s1:~/data.tar", `C:\data.tar`, remotePath{"s1", "~/data.tar"}, true, false},
+		{"./a:b", "s1:b", "./a:b", remotePath{"s1", "b"}, true, false},
+		{"s1:a", "s2:b", "", remotePath{}, false, true},
+		{"a", "b", "", remotePath{}, false, true},
+	}
+
+	for _, test := range tests {
+		local, remote, isUpload, err := parseTransferArgs(test.src, test.dst)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseTransferArgs(%q, %q) error = %v, want error %v", test.src, test.dst, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if local != test.local || *remote != test.remote || isUpload != test.isUpload {
+			t.Errorf("parseTransferArgs(%q, %q) = %q, %+v, %v, want %q, %+v, %v", test.src, test.dst, local, *remote, isUpload, test.local, test.remote, test.isUpload)
+		}
+	}
+}
+
+func TestRemoteShellPath(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", "."},
+		{"~", "."},
+		{"~/data set", "~/'data set'"},
+		{"/tmp/it's", `'/tmp/it'\''s'`},
+		{"~user/x", "'~user/x'"},
+	}
+
+	for _, test := range tests {
+		if got := remoteShellPath(test.value); got != test.want {
+			t.Errorf("remoteShellPath(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestLocalPrefixSum(t *testing.T) {
+	content := []byte("partial transfer contents")
+	file := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(file, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := func(data []byte) string {
+		hash := sha256.Sum256(data)
+		return hex.EncodeToString(hash[:])
+	}
+
+	tests := []struct {
+		name    string
+		n       int64
+		want    string
+		wantErr bool
+	}{
+		{"empty prefix", 0, sum(nil), false},
+		{"prefix", 7, sum(content[:7]), false},
+		{"whole file", int64(len(content)), sum(content), false},
+		{"longer than the file", int64(len(content)) + 1, "", true},
+	}
+
+	for _, test := range tests {
+		got, err := localPrefixSum(file, test.n)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: error = %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/browser"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	tunnelCmd = &cobra.Command{
+		Use:   "tunnel [flags] server_id forward...",
+		Short: "Forward local ports to a server",
+		Long: `Forwards local ports to addresses reachable from the server until interrupted.
+Each forward is written as [bind_address:]local_port:remote_host:remote_port,
+or just port to forward the same port to the server's localhost.`,
+		Example: `  tensordock-cli servers tunnel server_id 8888:localhost:8888 6006`,
+		Args:    cobra.MinimumNArgs(2),
+		RunE:    tunnelServer,
+	}
+	jupyterCmd = &cobra.Command{
+		Use:   "jupyter [flags] server_id",
+		Short: "Open Jupyter running on a server",
+		Long: `Starts JupyterLab on the server if nothing is listening on --remotePort yet,
+forwards the port and opens it in a browser.`,
+		Args: cobra.ExactArgs(1),
+		RunE: openJupyter,
+	}
+	tensorboardCmd = &cobra.Command{
+		Use:   "tensorboard [flags] server_id",
+		Short: "Open TensorBoard running on a server",
+		Long: `Starts TensorBoard on the server if nothing is listening on --remotePort yet,
+forwards the port and opens it in a browser.`,
+		Args: cobra.ExactArgs(1),
+		RunE: openTensorBoard,
+	}
+)
+
+type portForward struct {
+	Bind       string
+	LocalPort  int
+	RemoteHost string
+	RemotePort int
+}
+
+func (f portForward) localAddress() string {
+	return net.JoinHostPort(f.Bind, strconv.Itoa(f.LocalPort))
+}
+
+func (f portForward) remoteAddress() string {
+	return net.JoinHostPort(f.RemoteHost, strconv.Itoa(f.RemotePort))
+}
+
+func init() {
+	addSSHFlags(tunnelCmd.Flags())
+	serversCmd.AddCommand(tunnelCmd)
+
+	jupyterCmd.Flags().Int("remotePort", 8888, "Port Jupyter listens on on the server")
+	jupyterCmd.Flags().Int("localPort", 0, "Local port to forward (defaults to remotePort)")
+	jupyterCmd.Flags().String("dir", "", "Directory to start Jupyter in (defaults to the home directory)")
+	jupyterCmd.Flags().Bool("noBrowser", false, "Only print the URL instead of opening a browser")
+	addSSHFlags(jupyterCmd.Flags())
+	serversCmd.AddCommand(jupyterCmd)
+
+	tensorboardCmd.Flags().Int("remotePort", 6006, "Port TensorBoard listens on on the server")
+	tensorboardCmd.Flags().Int("localPort", 0, "Local port to forward (defaults to remotePort)")
+	tensorboardCmd.Flags().String("logdir", "runs", "Log directory to start TensorBoard with")
+	tensorboardCmd.Flags().Bool("noBrowser", false, "Only print the URL instead of opening a browser")
+	addSSHFlags(tensorboardCmd.Flags())
+	serversCmd.AddCommand(tensorboardCmd)
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", value)
+	}
+	return port, nil
+}
+
+// parseForward parses [bind_address:]local_port:remote_host:remote_port, or
+// a single port forwarded to the same port on the server's localhost.
+func parseForward(value string) (portForward, error) {
+	forward := portForward{Bind: "127.0.0.1", RemoteHost: "localhost"}
+	parts := strings.Split(value, ":")
+
+	var err error
+	switch len(parts) {
+	case 1:
+		forward.LocalPort, err = parsePort(parts[0])
+		forward.RemotePort = forward.LocalPort
+	case 3:
+		forward.RemoteHost = parts[1]
+		if forward.LocalPort, err = parsePort(parts[0]); err == nil {
+			forward.RemotePort, err = parsePort(parts[2])
+		}
+	case 4:
+		forward.Bind, forward.RemoteHost = parts[0], parts[2]
+		if forward.LocalPort, err = parsePort(parts[1]); err == nil {
+			forward.RemotePort, err = parsePort(parts[3])
+		}
+	default:
+		return forward, fmt.Errorf("invalid forward %q, expected [bind_address:]local_port:remote_host:remote_port", value)
+	}
+
+	if err != nil {
+		return forward, fmt.Errorf("invalid forward %q: %v", value, err)
+	}
+
+	if forward.RemoteHost == "" {
+		return forward, fmt.Errorf("invalid forward %q: missing remote host", value)
+	}
+
+	return forward, nil
+}
+
+// startForward listens locally and relays each connection through the SSH
+// connection until the listener is closed.
+func startForward(conn *ssh.Client, forward portForward) (net.Listener, error) {
+	listener, err := net.Listen("tcp", forward.localAddress())
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			local, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer local.Close()
+
+				remote, err := conn.Dial("tcp", forward.remoteAddress())
+				if err != nil {
+					log.Printf("failed to forward to %v: %v", forward.remoteAddress(), err)
+					return
+				}
+				defer remote.Close()
+
+				done := make(chan struct{}, 2)
+				go func() {
+					io.Copy(remote, local)
+					done <- struct{}{}
+				}()
+				go func() {
+					io.Copy(local, remote)
+					done <- struct{}{}
+				}()
+				<-done
+			}()
+		}
+	}()
+
+	return listener, nil
+}
+
+// waitForTunnels blocks until interrupted or the SSH connection drops.
+func waitForTunnels(conn *ssh.Client) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	closed := make(chan error, 1)
+	go func() { closed <- conn.Wait() }()
+
+	select {
+	case <-interrupt:
+		return nil
+	case err := <-closed:
+		if err == nil {
+			err = errors.New("connection closed")
+		}
+		return fmt.Errorf("connection to server lost: %v", err)
+	}
+}
+
+func tunnelServer(cmd *cobra.Command, args []string) error {
+	forwards := []portForward{}
+	for _, arg := range args[1:] {
+		forward, err := parseForward(arg)
+		if err != nil {
+			return err
+		}
+		forwards = append(forwards, forward)
+	}
+
+	target, err := sshTargetFor(cmd.Flags(), args[0])
+	if err != nil {
+		return err
+	}
+
+	conn, err := target.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, forward := range forwards {
+		listener, err := startForward(conn, forward)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+
+		log.Printf("forwarding %v to %v", forward.localAddress(), forward.remoteAddress())
+	}
+
+	log.Print("press Ctrl+C to stop")
+
+	return waitForTunnels(conn)
+}
+
+func isListening(conn *ssh.Client, port int) bool {
+	remote, err := conn.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	remote.Close()
+	return true
+}
+
+// startRemoteService runs command in the background on the server unless
+// something is already listening on the port, then waits for it to come up.
+// Values in env are sent over stdin and exported, so secrets don't show up
+// in the command line of any process on the server.
+func startRemoteService(conn *ssh.Client, name string, port int, command string, env map[string]string) (bool, error) {
+	if isListening(conn, port) {
+		return false, nil
+	}
+
+	log.Printf("starting %v on port %v", name, port)
+
+	var exports, stdin strings.Builder
+	for _, key := range sortedKeys(env) {
+		fmt.Fprintf(&exports, "IFS= read -r %[1]v && export %[1]v; ", key)
+		stdin.WriteString(env[key] + "\n")
+	}
+
+	logFile := fmt.Sprintf("~/.tensordock-%v.log", name)
+	script := fmt.Sprintf("%vcommand -v %v >/dev/null || { echo '%v is not installed' >&2; exit 1; }; nohup %v >%v 2>&1 </dev/null &", exports.String(), strings.Fields(command)[0], name, command, logFile)
+	if err := runSession(conn, script, stdin.String()); err != nil {
+		return false, fmt.Errorf("failed to start %v: %v", name, err)
+	}
+
+	deadline := time.Now().Add(60 * time.Second)
+	for !isListening(conn, port) {
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("%v did not start listening on port %v, see %v on the server", name, port, logFile)
+		}
+		time.Sleep(time.Second)
+	}
+
+	return true, nil
+}
+
+// openRemoteService forwards the service's port, opens the URL and keeps the
+// tunnel open until interrupted.
+func openRemoteService(cmd *cobra.Command, conn *ssh.Client, remotePort int, urlPath string) error {
+	flags := cmd.Flags()
+
+	localPort, err := flags.GetInt("localPort")
+	if err != nil {
+		return err
+	}
+
+	noBrowser, err := flags.GetBool("noBrowser")
+	if err != nil {
+		return err
+	}
+
+	if localPort == 0 {
+		localPort = remotePort
+	}
+
+	forward := portForward{Bind: "127.0.0.1", LocalPort: localPort, RemoteHost: "localhost", RemotePort: remotePort}
+	listener, err := startForward(conn, forward)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	url := fmt.Sprintf("http://%v%v", forward.localAddress(), urlPath)
+	log.Printf("forwarding %v, press Ctrl+C to stop", url)
+
+	if !noBrowser {
+		if err := browser.OpenURL(url); err != nil {
+			log.Printf("failed to open browser: %v", err)
+		}
+	}
+
+	return waitForTunnels(conn)
+}
+
+func randomToken() (string, error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// jupyterToken looks up the token of a Jupyter server already running on the
+// port, returning an empty string if it can't be determined.
+func jupyterToken(conn *ssh.Client, port int) string {
+	out, err := remoteOutput(conn, "jupyter server list 2>/dev/null || jupyter notebook list 2>/dev/null")
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.Contains(line, fmt.Sprintf(":%v/", port)) {
+			continue
+		}
+
+		if i := strings.Index(line, "token="); i >= 0 {
+			return strings.Fields(line[i+len("token="):])[0]
+		}
+	}
+
+	return ""
+}
+
+func openJupyter(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	remotePort, err := flags.GetInt("remotePort")
+	if err != nil {
+		return err
+	}
+
+	dir, err := flags.GetString("dir")
+	if err != nil {
+		return err
+	}
+
+	target, err := sshTargetFor(flags, args[0])
+	if err != nil {
+		return err
+	}
+
+	conn, err := target.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("jupyter lab --no-browser --ip=127.0.0.1 --port=%v", remotePort)
+	if dir != "" {
+		command += " --notebook-dir=" + remoteShellPath(dir)
+	}
+
+	// Jupyter reads the token from JUPYTER_TOKEN, which keeps it out of ps
+	started, err := startRemoteService(conn, "jupyter", remotePort, command, map[string]string{"JUPYTER_TOKEN": token})
+	if err != nil {
+		return err
+	}
+
+	if !started {
+		token = jupyterToken(conn, remotePort)
+	}
+
+	urlPath := "/lab"
+	if token != "" {
+		urlPath += "?token=" + token
+	}
+
+	return openRemoteService(cmd, conn, remotePort, urlPath)
+}
+
+func openTensorBoard(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	remotePort, err := flags.GetInt("remotePort")
+	if err != nil {
+		return err
+	}
+
+	logdir, err := flags.GetString("logdir")
+	if err != nil {
+		return err
+	}
+
+	target, err := sshTargetFor(flags, args[0])
+	if err != nil {
+		return err
+	}
+
+	conn, err := target.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	command := fmt.Sprintf("tensorboard --logdir %v --host 127.0.0.1 --port %v", remoteShellPath(logdir), remotePort)
+	if _, err := startRemoteService(conn, "tensorboard", remotePort, command, nil); err != nil {
+		return err
+	}
+
+	return openRemoteService(cmd, conn, remotePort, "/")
+}
@@ -0,0 +1,35 @@
+package commands
+
+import "testing"
+
+func TestParseForward(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    portForward
+		wantErr bool
+	}{
+		{"8888", portForward{"127.0.0.1", 8888, "localhost", 8888}, false},
+		{"8080:localhost:80", portForward{"127.0.0.1", 8080, "localhost", 80}, false},
+		{"6006:10.0.0.2:6006", portForward{"127.0.0.1", 6006, "10.0.0.2", 6006}, false},
+		{"0.0.0.0:8888:localhost:8888", portForward{"0.0.0.0", 8888, "localhost", 8888}, false},
+		{":8888:localhost:8888", portForward{"", 8888, "localhost", 8888}, false},
+		{"0", portForward{}, true},
+		{"65536", portForward{}, true},
+		{"http", portForward{}, true},
+		{"8888:localhost", portForward{}, true},
+		{"8888::80", portForward{}, true},
+		{"8888:localhost:web", portForward{}, true},
+		{"a:b:c:d:e", portForward{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseForward(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseForward(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && got != test.want {
+			t.Errorf("parseForward(%q) = %+v, want %+v", test.value, got, test.want)
+		}
+	}
+}
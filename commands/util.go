@@ -0,0 +1,14 @@
+package commands
+
+import "sort"
+
+// sortedKeys returns the keys of a map in ascending order, for printing
+// maps deterministically.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
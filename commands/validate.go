@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+)
+
+// validateDeployRequest runs the request's own checks plus a stock check,
+// and reports every problem at once. Models, locations and operating systems
+// missing from the catalog are only warned about since the catalog may be
+// out of date. models and locations hold the candidates when fallbacks or
+// automatic placement are used; stock is only checked when there is a
+// single candidate.
+func validateDeployRequest(req api.DeployServerRequest, models []string, locations []string, checkStock bool) error {
+	verr := &api.ValidationError{}
+	if err := req.Validate(); err != nil {
+		if !errors.As(err, &verr) {
+			return err
+		}
+	}
+
+	warnings := req.Warnings()
+
+	if req.InstanceType == "gpu" {
+		for _, model := range models {
+			if err := checkCatalogGpu(model); err != nil {
+				warnings = append(warnings, err.Error())
+			}
+		}
+	}
+
+	for _, location := range locations {
+		if location == "auto" {
+			continue
+		}
+		if err := checkCatalogLocation(location); err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	}
+
+	if err := checkCatalogOS(req.OS); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+
+	logWarnings(warnings)
+
+	if checkStock && len(models) == 1 && len(locations) == 1 && (req.InstanceType == "gpu" || req.InstanceType == "cpu") {
+		need := 1
+		if req.InstanceType == "gpu" {
+			need = req.GPUCount
+		}
+
+		if err := checkStockAvailable(req.InstanceType, models[0], locations[0], need); err != nil {
+			verr.Add("%v", err)
+		}
+	}
+
+	return verr.OrNil()
+}
+
+// checkStockAvailable returns an error unless at least need units of the
+// model are available now in the location. If the stock can't be fetched
+// the check is skipped with a warning, leaving it to the API to refuse.
+func checkStockAvailable(instanceType string, model string, location string, need int) error {
+	entries, err := fetchStock(instanceType)
+	if err != nil {
+		log.Printf("warning: failed to check stock, skipping the check: %v", err)
+		return nil
+	}
+
+	available := 0
+	for _, entry := range entries {
+		if entry.Model == model && entry.Location == location {
+			available = entry.AvailableNow
+		}
+	}
+
+	if available < need {
+		return fmt.Errorf("%v in %v has %v available now, %v needed", model, location, available, need)
+	}
+
+	return nil
+}
+
+func validateModifyRequest(req api.ModifyServerRequest) error {
+	warnings := req.Warnings()
+
+	if req.GPUModel != nil && *req.GPUModel != "" {
+		if err := checkCatalogGpu(*req.GPUModel); err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	}
+
+	logWarnings(warnings)
+
+	return req.Validate()
+}
+
+func logWarnings(warnings []string) {
+	for _, warning := range warnings {
+		log.Printf("warning: %v", warning)
+	}
+}
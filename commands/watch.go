@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// watchSpec describes a list command that can be printed once or polled
+// with --watch. Items are identified by key so that changes between polls
+// can be reported as events in JSON mode.
+type watchSpec[T any] struct {
+	fetch  func() ([]T, error)
+	key    func(T) string
+	render func([]T) error
+	// equal reports whether two versions of an item are the same for the
+	// purpose of change events, comparing every field if nil
+	equal func(before T, after T) bool
+	// change names the kind of change between two versions of an item and
+	// optionally returns extra details such as deltas
+	change func(before T, after T) (string, interface{})
+}
+
+type changeEvent struct {
+	Time   time.Time   `json:"time"`
+	Event  string      `json:"event"`
+	Key    string      `json:"key"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	Delta  interface{} `json:"delta,omitempty"`
+}
+
+func addWatchFlags(flags *pflag.FlagSet) {
+	flags.BoolP("watch", "w", false, "Keep polling and redraw, or print change events with --output json")
+	flags.Duration("interval", 10*time.Second, "Time between polls with --watch")
+	flags.String("output", "table", "Output format (table or json)")
+}
+
+func (spec watchSpec[T]) run(flags *pflag.FlagSet) error {
+	watch, err := flags.GetBool("watch")
+	if err != nil {
+		return err
+	}
+
+	interval, err := flags.GetDuration("interval")
+	if err != nil {
+		return err
+	}
+
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+
+	if output != "table" && output != "json" {
+		return fmt.Errorf("invalid output %v, must be table or json", output)
+	}
+
+	if !watch {
+		items, err := spec.fetch()
+		if err != nil {
+			return err
+		}
+
+		if output == "json" {
+			return writeJSON(items)
+		}
+		return spec.render(items)
+	}
+
+	if output == "json" {
+		return spec.watchEvents(interval)
+	}
+
+	return spec.watchTable(interval)
+}
+
+// watchTable redraws the table in place on a terminal, or prints a new
+// timestamped table on each poll otherwise.
+func (spec watchSpec[T]) watchTable(interval time.Duration) error {
+	tty := term.IsTerminal(int(os.Stdout.Fd()))
+
+	for {
+		items, err := spec.fetch()
+
+		if tty {
+			fmt.Print("\x1b[H\x1b[2J")
+		}
+		fmt.Printf("Every %v, updated %v\n", interval, time.Now().Format("15:04:05"))
+
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+		} else if err := spec.render(items); err != nil {
+			return err
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (spec watchSpec[T]) same(before T, after T) bool {
+	if spec.equal != nil {
+		return spec.equal(before, after)
+	}
+	return reflect.DeepEqual(before, after)
+}
+
+// watchEvents prints one JSON object per line for every item that was
+// added, removed or changed since the previous poll. Items present on the
+// first poll are reported as added.
+func (spec watchSpec[T]) watchEvents(interval time.Duration) error {
+	encoder := json.NewEncoder(os.Stdout)
+	previous := map[string]T{}
+
+	for {
+		items, err := spec.fetch()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			time.Sleep(interval)
+			continue
+		}
+
+		now := time.Now()
+		events := []changeEvent{}
+		current := map[string]T{}
+
+		for _, item := range items {
+			key := spec.key(item)
+			current[key] = item
+
+			before, ok := previous[key]
+			switch {
+			case !ok:
+				events = append(events, changeEvent{Time: now, Event: "added", Key: key, After: item})
+			case !spec.same(before, item):
+				event, delta := spec.change(before, item)
+				events = append(events, changeEvent{Time: now, Event: event, Key: key, Before: before, After: item, Delta: delta})
+			}
+		}
+
+		for _, key := range sortedKeys(previous) {
+			if _, ok := current[key]; !ok {
+				events = append(events, changeEvent{Time: now, Event: "removed", Key: key, Before: previous[key]})
+			}
+		}
+
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+
+		previous = current
+		time.Sleep(interval)
+	}
+}
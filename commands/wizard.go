@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/caguiclajmg/tensordock-cli/api"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// deployWizard walks through the deploy options, using the values already
+// in req as defaults, and reports whether the user confirmed the result.
+func deployWizard(req *api.DeployServerRequest) (bool, error) {
+	types := []string{"gpu", "cpu"}
+	def := 0
+	if req.InstanceType == "cpu" {
+		def = 1
+	}
+
+	choice, err := promptChoice("Instance type", types, def)
+	if err != nil {
+		return false, err
+	}
+	req.InstanceType = types[choice]
+
+	entries, err := fetchStock(req.InstanceType)
+	if err != nil {
+		return false, err
+	}
+
+	available := []stockEntry{}
+	for _, entry := range entries {
+		if entry.AvailableNow > 0 {
+			available = append(available, entry)
+		}
+	}
+
+	if len(available) == 0 {
+		return false, errors.New("no stock available for this instance type")
+	}
+
+	options := []string{}
+	for _, entry := range available {
+		option := fmt.Sprintf("%v in %v, %v available", entry.Model, entry.Location, entry.AvailableNow)
+		if label := locationLabel(entry.Location); label != "" {
+			option = fmt.Sprintf("%v in %v (%v), %v available", entry.Model, entry.Location, label, entry.AvailableNow)
+		}
+		if hourly, ok := gpuHourlyPrice(entry.Model); ok && req.InstanceType == "gpu" {
+			option += fmt.Sprintf(", $%.2f/hr per GPU", hourly)
+		}
+		options = append(options, option)
+	}
+
+	choice, err = promptChoice("Model and location", options, 0)
+	if err != nil {
+		return false, err
+	}
+
+	stock := available[choice]
+	req.Location = stock.Location
+	if req.InstanceType == "gpu" {
+		req.GPUModel = stock.Model
+		req.CPUModel = ""
+
+		count := req.GPUCount
+		if count > stock.AvailableNow {
+			count = stock.AvailableNow
+		}
+
+		if req.GPUCount, err = promptInt("GPU count", count, 1, stock.AvailableNow); err != nil {
+			return false, err
+		}
+	} else {
+		req.CPUModel = stock.Model
+		req.GPUModel = ""
+		req.GPUCount = 0
+	}
+
+	if req.VCPUs, err = promptInt("vCPUs", req.VCPUs, 1, 0); err != nil {
+		return false, err
+	}
+
+	if req.RAM, err = promptInt("RAM (GB)", req.RAM, 1, 0); err != nil {
+		return false, err
+	}
+
+	if req.Storage, err = promptInt("Storage (GB)", req.Storage, 1, 0); err != nil {
+		return false, err
+	}
+
+	def = 0
+	for i, class := range api.StorageClasses {
+		if class == req.StorageClass {
+			def = i
+		}
+	}
+
+	if choice, err = promptChoice("Storage class", api.StorageClasses, def); err != nil {
+		return false, err
+	}
+	req.StorageClass = api.StorageClasses[choice]
+
+	systems := getCatalog().OS
+	def = 0
+	for i, os := range systems {
+		if os == req.OS {
+			def = i
+		}
+	}
+
+	if choice, err = promptChoice("Operating system", systems, def); err != nil {
+		return false, err
+	}
+	req.OS = systems[choice]
+
+	for req.Name == "" {
+		if req.Name, err = promptString("Server name", ""); err != nil {
+			return false, err
+		}
+	}
+
+	if req.AdminUser == "" {
+		req.AdminUser = "user"
+	}
+
+	if req.AdminUser, err = promptString("Admin user", req.AdminUser); err != nil {
+		return false, err
+	}
+
+	if req.AdminPass == "" {
+		generate, err := promptConfirm("Generate a random admin password?")
+		if err != nil {
+			return false, err
+		}
+
+		if generate {
+			if req.AdminPass, err = generatePassword(); err != nil {
+				return false, err
+			}
+			fmt.Printf("Admin password: %v\n", req.AdminPass)
+		} else {
+			if req.AdminPass, err = promptPassword("Admin password"); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	printDeploySummary(*req)
+
+	return promptConfirm("Deploy this server?")
+}
+
+func printDeploySummary(req api.DeployServerRequest) {
+	var model string
+	if req.InstanceType == "gpu" {
+		model = fmt.Sprintf("%v x%v", req.GPUModel, req.GPUCount)
+	} else {
+		model = req.CPUModel
+	}
+
+	cost := "unknown"
+	if hourly, ok := estimateHourlyCost(req); ok {
+		cost = fmt.Sprintf("~$%.2f/hr, ~$%.2f/month", hourly, hourly*24*30)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Property", "Value"})
+	t.AppendRows([]table.Row{
+		{"Name", req.Name},
+		{"Location", req.Location},
+		{"Type", req.InstanceType},
+		{"Model", model},
+		{"vCPUs", req.VCPUs},
+		{"RAM", fmt.Sprintf("%vGB", req.RAM)},
+		{"Storage", fmt.Sprintf("%vGB %v", req.Storage, req.StorageClass)},
+		{"OS", req.OS},
+		{"Admin User", req.AdminUser},
+		{"Estimated Cost", cost},
+	})
+	t.Render()
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/caguiclajmg/tensordock-cli/commands"
 )
 
 func main() {
 	log.SetFlags(log.Flags() &^ (log.Ldate | log.Ltime))
-	commands.Execute()
+	if err := commands.Execute(); err != nil {
+		os.Exit(commands.ExitCode(err))
+	}
 }